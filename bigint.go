@@ -0,0 +1,40 @@
+package muhash
+
+import (
+	"math/big"
+
+	"github.com/pkg/errors"
+)
+
+// This file converts MuHashes to and from math/big integers, for research and cross-checking with ad-hoc modular
+// arithmetic. The conversions allocate and aren't meant for the hot path, the rest of the package doesn't use math/big.
+
+// NumeratorBigInt returns the value of the MuHash as a big.Int in [0, prime), i.e. the numerator after normalizing
+// a copy of the MuHash. The MuHash itself isn't modified.
+func (mu *MuHash) NumeratorBigInt() *big.Int {
+	serialized := mu.SerializeBigEndian()
+	return new(big.Int).SetBytes(serialized[:])
+}
+
+// MuHashFromBigInt returns a MuHash whose value is n, the inverse of NumeratorBigInt.
+// An error is returned if n is zero or negative, or if it overflows the field, i.e. n >= prime.
+func MuHashFromBigInt(n *big.Int) (*MuHash, error) {
+	if n.Sign() < 0 {
+		return nil, errors.Errorf("A MuHash can't be negative, got %s", n)
+	}
+	if n.Cmp(primeBigInt()) >= 0 {
+		return nil, errors.Wrap(ErrOverflow, "value exceeds the field prime")
+	}
+	var serialized [SerializedMuHashSize]byte
+	n.FillBytes(serialized[:])
+	return DeserializeMuHashBigEndian(&serialized)
+}
+
+func primeBigInt() *big.Int {
+	var primeBytes [elementByteSize]byte
+	wordsToBytesLE(&primeUint3072, &primeBytes)
+	for i := 0; i < len(primeBytes)/2; i++ {
+		primeBytes[i], primeBytes[len(primeBytes)-1-i] = primeBytes[len(primeBytes)-1-i], primeBytes[i]
+	}
+	return new(big.Int).SetBytes(primeBytes[:])
+}
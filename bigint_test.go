@@ -0,0 +1,82 @@
+package muhash
+
+import (
+	"errors"
+	"math/big"
+	"math/rand"
+	"testing"
+)
+
+func TestMuHash_NumeratorBigInt(t *testing.T) {
+	t.Parallel()
+	if primeBigInt().Cmp(prime) != 0 {
+		t.Fatalf("Expected %x == %x", primeBigInt(), prime)
+	}
+	if n := NewMuHash().NumeratorBigInt(); n.Cmp(big.NewInt(1)) != 0 {
+		t.Fatalf("Expected the empty set to be 1, got %s", n)
+	}
+
+	set := NewMuHash()
+	reference := newBigMuHash()
+	for i := byte(0); i < 5; i++ {
+		set.Add(elementFromByte(i))
+		reference.Add(elementFromByte(i))
+	}
+	set.Remove(elementFromByte(10))
+	reference.Remove(elementFromByte(10))
+	before := *set
+	n := set.NumeratorBigInt()
+	if *set != before {
+		t.Fatalf("Expected NumeratorBigInt not to modify the MuHash")
+	}
+	expected := new(big.Int).ModInverse(reference.denominator, prime)
+	expected.Mul(expected, reference.numerator)
+	expected.Mod(expected, prime)
+	if n.Cmp(expected) != 0 {
+		t.Fatalf("Expected %x == %x", n, expected)
+	}
+
+	fromBigInt, err := MuHashFromBigInt(n)
+	if err != nil {
+		t.Fatalf("MuHashFromBigInt: %v", err)
+	}
+	if fromBigInt.Finalize() != set.Finalize() {
+		t.Fatalf("Expected %s == %s", fromBigInt.Finalize(), set.Finalize())
+	}
+}
+
+func TestMuHashFromBigInt(t *testing.T) {
+	t.Parallel()
+	r := rand.New(rand.NewSource(0))
+	for i := 0; i < 100; i++ {
+		n := new(big.Int).Rand(r, prime)
+		mu, err := MuHashFromBigInt(n)
+		if err != nil {
+			t.Fatalf("MuHashFromBigInt: %v", err)
+		}
+		if roundTrip := mu.NumeratorBigInt(); roundTrip.Cmp(n) != 0 {
+			t.Fatalf("Expected %x == %x", roundTrip, n)
+		}
+	}
+
+	tests := []struct {
+		n         *big.Int
+		expectErr bool
+	}{
+		{big.NewInt(0), true},
+		{new(big.Int).Sub(prime, big.NewInt(1)), false},
+		{prime, true},
+		{new(big.Int).Add(prime, big.NewInt(1)), true},
+		{new(big.Int).Lsh(big.NewInt(1), elementBitSize+8), true},
+		{big.NewInt(-1), true},
+	}
+	for _, test := range tests {
+		_, err := MuHashFromBigInt(test.n)
+		if (err != nil) != test.expectErr {
+			t.Fatalf("MuHashFromBigInt(%x): expected error: %t, got %v", test.n, test.expectErr, err)
+		}
+		if err != nil && test.n.Cmp(prime) >= 0 && !errors.Is(err, ErrOverflow) {
+			t.Fatalf("Expected %s, instead found: %v", ErrOverflow, err)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package muhash
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// SerializedBitcoinCoreMuHashSize defines the length in bytes of SerializedBitcoinCoreMuHash
+const SerializedBitcoinCoreMuHashSize = 2 * elementByteSize
+
+// BitcoinCoreMuHash is a MuHash compatible with Bitcoin Core's MuHash3072.
+// It uses the same field as MuHash, but derives the elements from their SHA256 hash (instead of blake2b),
+// and finalizes with SHA256, so it must never be combined with a regular MuHash.
+// Use NewBitcoinCoreMuHash to initialize a BitcoinCoreMuHash, or DeserializeBitcoinCoreMuHash to parse one.
+type BitcoinCoreMuHash struct {
+	inner MuHash
+}
+
+// SerializedBitcoinCoreMuHash is a byte array representing a BitcoinCoreMuHash the same way Bitcoin Core serializes
+// a MuHash3072: the little endian numerator followed by the little endian denominator.
+type SerializedBitcoinCoreMuHash [SerializedBitcoinCoreMuHashSize]byte
+
+// String returns the SerializedBitcoinCoreMuHash as the hexadecimal string
+func (serialized SerializedBitcoinCoreMuHash) String() string {
+	return hex.EncodeToString(serialized[:])
+}
+
+// NewBitcoinCoreMuHash return an empty initialized Bitcoin Core compatible set.
+func NewBitcoinCoreMuHash() *BitcoinCoreMuHash {
+	return &BitcoinCoreMuHash{inner: *NewMuHashWithHasher(sha256.Sum256)}
+}
+
+// Add hashes the data like Bitcoin Core's MuHash3072::Insert and adds it to the set.
+func (mu *BitcoinCoreMuHash) Add(data []byte) {
+	mu.inner.Add(data)
+}
+
+// Remove hashes the data like Bitcoin Core's MuHash3072::Remove and removes it from the set.
+func (mu *BitcoinCoreMuHash) Remove(data []byte) {
+	mu.inner.Remove(data)
+}
+
+// Combine will add the sets together. Equivalent to manually adding all the data elements
+// from one set to the other.
+func (mu *BitcoinCoreMuHash) Combine(other *BitcoinCoreMuHash) {
+	mu.inner.Combine(&other.inner)
+}
+
+// Finalize returns the same hash as Bitcoin Core's MuHash3072::Finalize, a SHA256 of the normalized set.
+// The bytes are in the order Bitcoin Core stores its uint256, so Bitcoin Core displays them reversed.
+// Finalize doesn't modify the set.
+func (mu *BitcoinCoreMuHash) Finalize() Hash {
+	var serialized SerializedMuHash
+	mu.inner.SerializeToArray(&serialized)
+	return sha256.Sum256(serialized[:])
+}
+
+// Serialize returns the set serialized exactly like Bitcoin Core serializes a MuHash3072.
+// Like in Bitcoin Core the set isn't normalized, so the serialization of two equal sets might be different.
+func (mu *BitcoinCoreMuHash) Serialize() *SerializedBitcoinCoreMuHash {
+	out := SerializedBitcoinCoreMuHash(mu.inner.SerializeRaw())
+	return &out
+}
+
+// DeserializeBitcoinCoreMuHash will deserialize a Bitcoin Core MuHash3072 serialization.
+// Like Bitcoin Core, any numerator and denominator are accepted, even ones that aren't fully reduced.
+func DeserializeBitcoinCoreMuHash(serialized *SerializedBitcoinCoreMuHash) *BitcoinCoreMuHash {
+	mu := NewBitcoinCoreMuHash()
+	raw := DeserializeRaw((*[SerializedBitcoinCoreMuHashSize]byte)(serialized))
+	mu.inner.numerator = raw.numerator
+	mu.inner.denominator = raw.denominator
+	return mu
+}
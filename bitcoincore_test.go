@@ -0,0 +1,83 @@
+package muhash
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+// reversedHash returns the hash in the byte order Bitcoin Core uses to display a uint256.
+func reversedHash(hash Hash) string {
+	for i := 0; i < HashSize/2; i++ {
+		hash[i], hash[HashSize-1-i] = hash[HashSize-1-i], hash[i]
+	}
+	return hash.String()
+}
+
+// The test vectors are from Bitcoin Core's muhash_tests.
+func TestBitcoinCoreMuHash_Vectors(t *testing.T) {
+	t.Parallel()
+	expected := "10d312b100cbd32ada024a6646e40d3482fcff103668d2625f10002a607d5863"
+	acc := NewBitcoinCoreMuHash()
+	acc.Add(elementFromByte(0))
+	acc.Add(elementFromByte(1))
+	acc.Remove(elementFromByte(2))
+	if reversedHash(acc.Finalize()) != expected {
+		t.Fatalf("Expected %s == %s", expected, reversedHash(acc.Finalize()))
+	}
+
+	other := NewBitcoinCoreMuHash()
+	other.Add(elementFromByte(0))
+	acc = NewBitcoinCoreMuHash()
+	acc.Add(elementFromByte(1))
+	acc.Remove(elementFromByte(2))
+	acc.Combine(other)
+	if reversedHash(acc.Finalize()) != expected {
+		t.Fatalf("Expected %s == %s", expected, reversedHash(acc.Finalize()))
+	}
+
+	kaspaSet := NewMuHash()
+	kaspaSet.Add(elementFromByte(0))
+	kaspaSet.Add(elementFromByte(1))
+	kaspaSet.Remove(elementFromByte(2))
+	kaspaHash := kaspaSet.Finalize()
+	if acc.Finalize().IsEqual(&kaspaHash) {
+		t.Fatalf("Bitcoin Core sets shouldn't have the same hash as regular sets")
+	}
+}
+
+func TestBitcoinCoreMuHash_Serialize(t *testing.T) {
+	t.Parallel()
+	expected, err := hex.DecodeString("1fa093295ea30a6a3acdc7b3f770fa538eff537528e990e2910e40bbcfd7f6696b1256901929094694b56316de342f593303dd12ac43e06dce1be1ff8301c845beb15468fff0ef002dbf80c29f26e6452bccc91b5cb9437ad410d2a67ea847887fa3c6a6553309946880fe20db2c73fe0641adbd4e86edfee0d9f8cd0ee1230898873dc13ed8ddcaf045c80faa082774279007a2253f8922ee3ef361d378a6af3ddaf180b190ac97e556888c36b3d1fb1c85aab9ccd46e3deaeb7b7cf5db067a7e9ff86b658cf3acd6662bbcce37232daa753c48b794356c020090c831a8304416e2aa7ad633c0ddb2f11be1be316a81be7f7e472071c042cb68faef549c221ebff209273638b741aba5a81675c45a5fa92fea4ca821d7a324cb1e1a2ccd3b76c4228ec8066dad2a5df6e1bd0de45c7dd5de8070bdb46db6c554cf9aefc9b7b2bbf9f75b1864d9f95005314593905c0109b71f703d49944ae94477b51dac10a816bb6d1c700bafabc8bd86fac8df24be519a2f2836b16392e18036cb13e48c5c010000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000")
+	if err != nil {
+		t.Fatalf("Failed deserializing hex string: %v", err)
+	}
+	check := NewBitcoinCoreMuHash()
+	check.Add(elementFromByte(1))
+	check.Add(elementFromByte(2))
+	serialized := check.Serialize()
+	if !bytes.Equal(expected, serialized[:]) {
+		t.Fatalf("Expected %x == %s", expected, serialized)
+	}
+
+	deserialized := DeserializeBitcoinCoreMuHash(serialized)
+	if *deserialized.Serialize() != *serialized {
+		t.Fatalf("Expected %s == %s", deserialized.Serialize(), serialized)
+	}
+	checkHash := check.Finalize()
+	if !deserialized.Finalize().IsEqual(&checkHash) {
+		t.Fatalf("Expected %s == %s", deserialized.Finalize(), checkHash)
+	}
+
+	// The denominator isn't normalized by serialization.
+	check.Remove(elementFromByte(3))
+	serialized = check.Serialize()
+	if bytes.Equal(serialized[elementByteSize:], expected[elementByteSize:]) {
+		t.Fatalf("Expected the denominator to be serialized, found: %s", serialized)
+	}
+	deserialized = DeserializeBitcoinCoreMuHash(serialized)
+	checkHash = check.Finalize()
+	if !deserialized.Finalize().IsEqual(&checkHash) {
+		t.Fatalf("Expected %s == %s", deserialized.Finalize(), checkHash)
+	}
+}
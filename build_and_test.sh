@@ -17,6 +17,22 @@ staticcheck -checks=all -tags=gofuzz ./...
 
 go vet $FLAGS -tags=gofuzz ./...
 
+go vet $FLAGS -tags=gofuzz,muhash_cgo ./...
+
 go build $FLAGS .
 
-go test $FLAGS -tags=gofuzz ./...
\ No newline at end of file
+GOOS=js GOARCH=wasm go vet $FLAGS ./...
+
+GOOS=js GOARCH=wasm go build $FLAGS .
+
+go test $FLAGS -tags=gofuzz ./...
+
+go test $FLAGS -tags=gofuzz,purego ./...
+
+go test $FLAGS -tags=gofuzz,muhash_debug ./...
+
+GOARCH=386 go vet $FLAGS ./...
+
+GOARCH=386 go test $FLAGS ./...
+
+go test $FLAGS -tags=gofuzz,muhash_cgo ./...
\ No newline at end of file
@@ -0,0 +1,63 @@
+package muhash
+
+// CachedMuHash is a MuHash that remembers its finalized hash until the set changes, for callers that finalize
+// the same set repeatedly, e.g. to answer queries for a commitment that only changes once per block.
+// MuHash itself never caches, so it can be finalized, cloned and printed concurrently and compared with ==.
+// Finalize on a CachedMuHash writes the cache, so it mustn't be called concurrently with any other method.
+// Use NewCachedMuHash to initialize a CachedMuHash.
+type CachedMuHash struct {
+	inner     MuHash
+	finalized Hash
+	clean     bool
+}
+
+// NewCachedMuHash returns an empty initialized cached set.
+func NewCachedMuHash() *CachedMuHash {
+	return &CachedMuHash{inner: *NewMuHash()}
+}
+
+// Add hashes the data and adds it to the set.
+func (cached *CachedMuHash) Add(data []byte) {
+	cached.clean = false
+	cached.inner.Add(data)
+}
+
+// Remove hashes the data and removes it from the set.
+func (cached *CachedMuHash) Remove(data []byte) {
+	cached.clean = false
+	cached.inner.Remove(data)
+}
+
+// Combine will add the MuHash together. Equivalent to manually adding all the data elements
+// from one set to the other.
+func (cached *CachedMuHash) Combine(other *MuHash) {
+	cached.clean = false
+	cached.inner.Combine(other)
+}
+
+// Reset clears the set so it can be reused, as if it was returned from NewCachedMuHash.
+func (cached *CachedMuHash) Reset() {
+	cached.clean = false
+	cached.inner.Reset()
+}
+
+// Clone the set to create a new one, including its cached hash. Changing one doesn't affect the other.
+func (cached *CachedMuHash) Clone() *CachedMuHash {
+	clone := *cached
+	return &clone
+}
+
+// MuHash returns a copy of the set as a MuHash, e.g. to serialize it or to combine it with other sets.
+func (cached *CachedMuHash) MuHash() *MuHash {
+	return cached.inner.Clone()
+}
+
+// Finalize will return a hash(Blake2b) of the set. It's the same as the finalized hash of a MuHash with
+// the same elements added and removed, but it's only computed again after the set changed.
+func (cached *CachedMuHash) Finalize() Hash {
+	if !cached.clean {
+		cached.finalized = cached.inner.Finalize()
+		cached.clean = true
+	}
+	return cached.finalized
+}
@@ -0,0 +1,68 @@
+package muhash
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestCachedMuHash(t *testing.T) {
+	t.Parallel()
+	cached := NewCachedMuHash()
+	expected := NewMuHash()
+	check := func(operation string) {
+		if cached.Finalize() != expected.Finalize() {
+			t.Fatalf("Found a stale finalized hash after %s: %s != %s", operation, cached.Finalize(), expected.Finalize())
+		}
+		// Finalizing again should return the cached hash.
+		if cached.Finalize() != expected.Finalize() {
+			t.Fatalf("Finalizing twice after %s returned a different hash: %s != %s",
+				operation, cached.Finalize(), expected.Finalize())
+		}
+	}
+
+	check("NewCachedMuHash")
+	cached.Add(elementFromByte(1))
+	expected.Add(elementFromByte(1))
+	check("Add")
+	cached.Remove(elementFromByte(2))
+	expected.Remove(elementFromByte(2))
+	check("Remove")
+
+	other := NewMuHash()
+	other.Add(elementFromByte(3))
+	cached.Combine(other)
+	expected.Combine(other)
+	check("Combine")
+
+	clone := cached.Clone()
+	clone.Add(elementFromByte(4))
+	check("adding to a clone")
+	if clone.Finalize() == cached.Finalize() {
+		t.Fatalf("Expected a changed clone not to return the cached hash of the original")
+	}
+	if !cached.MuHash().Serialize().Equal(expected.Serialize()) {
+		t.Fatalf("Expected the MuHash of the cached set to equal a MuHash of its elements")
+	}
+
+	cached.Reset()
+	expected.Reset()
+	check("Reset")
+	if cached.Finalize() != EmptyMuHashHash {
+		t.Fatalf("Expected %s == %s", cached.Finalize(), EmptyMuHashHash)
+	}
+}
+
+func BenchmarkCachedMuHash_Finalize(b *testing.B) {
+	r := rand.New(rand.NewSource(0))
+	cached := NewCachedMuHash()
+	for i := range cached.inner.numerator {
+		cached.inner.numerator[i] = uint(r.Uint64())
+		cached.inner.denominator[i] = uint(r.Uint64())
+	}
+	cached.Finalize()
+	b.ResetTimer()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		cached.Finalize()
+	}
+}
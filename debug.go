@@ -0,0 +1,36 @@
+//go:build muhash_debug
+// +build muhash_debug
+
+package muhash
+
+import (
+	"math/big"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// debugPrime is the prime as a big.Int, so that the invariant checks don't rely on the limb arithmetic they check.
+var debugPrime = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), elementBitSize), big.NewInt(primeDiff))
+
+// debugBigIntPool reuses the big.Ints of the checks, so that the allocation tests pass in debug builds too.
+var debugBigIntPool = sync.Pool{
+	New: func() interface{} {
+		return new(big.Int)
+	},
+}
+
+// checkReduced panics if the number isn't fully reduced, i.e. if it isn't smaller than the prime.
+// It's compiled in only with the muhash_debug build tag, and compares with math/big instead of IsOverflow.
+func checkReduced(num *uint3072) {
+	var numBytes [elementByteSize]byte
+	wordsToBytesLE(num, &numBytes)
+	for i := 0; i < len(numBytes)/2; i++ {
+		numBytes[i], numBytes[len(numBytes)-1-i] = numBytes[len(numBytes)-1-i], numBytes[i]
+	}
+	numBig := debugBigIntPool.Get().(*big.Int)
+	defer debugBigIntPool.Put(numBig)
+	if numBig.SetBytes(numBytes[:]).Cmp(debugPrime) >= 0 {
+		panic(errors.Errorf("the result %x isn't reduced modulo the prime", numBytes))
+	}
+}
@@ -0,0 +1,23 @@
+//go:build muhash_debug
+// +build muhash_debug
+
+package muhash
+
+import "testing"
+
+func TestCheckReduced(t *testing.T) {
+	t.Parallel()
+	primeMinusOne := primeUint3072
+	primeMinusOne[0]--
+	checkReduced(&primeMinusOne)
+	for _, num := range []uint3072{primeUint3072, maxMuHash.numerator} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Fatalf("Expected checkReduced(%v) to panic", num)
+				}
+			}()
+			checkReduced(&num)
+		}()
+	}
+}
@@ -0,0 +1,145 @@
+//go:build go1.18
+// +build go1.18
+
+package muhash
+
+import (
+	"encoding/binary"
+	"errors"
+	"math/big"
+	"testing"
+)
+
+func FuzzSerializeRoundTrip(f *testing.F) {
+	for _, test := range testVectors {
+		f.Add(test.dataElement)
+	}
+	f.Add([]byte{})
+	// The field boundary: p-1, p and 2^3072-1.
+	max := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 3072), big.NewInt(1))
+	for _, boundary := range []*big.Int{new(big.Int).Sub(prime, big.NewInt(1)), prime, max} {
+		f.Add(bigIntToLEBytes(boundary))
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		set := NewMuHash()
+		set.Add(data)
+		set.Remove(data[:len(data)/2])
+		deserialized, err := DeserializeMuHash(set.Serialize())
+		if err != nil {
+			t.Fatalf("Failed deserializing a serialized muhash: %v", err)
+		}
+		expected := set.Finalize()
+		if !deserialized.Finalize().IsEqual(&expected) {
+			t.Fatalf("Expected %s == %s", deserialized.Finalize(), expected)
+		}
+
+		if len(data) < elementByteSize {
+			return
+		}
+		var serialized SerializedMuHash
+		copy(serialized[:], data)
+		serializedInt := leBytesToBigInt(serialized[:])
+		isOverflow := serializedInt.Cmp(prime) >= 0
+		deserialized, err = DeserializeMuHash(&serialized)
+		if serializedInt.Sign() == 0 {
+			if !errors.Is(err, ErrZeroMuHash) {
+				t.Fatalf("Expected %s, instead found: %v", ErrZeroMuHash, err)
+			}
+			return
+		}
+		if isOverflow {
+			if !errors.Is(err, ErrOverflow) {
+				t.Fatalf("Expected %s, instead found: %v", ErrOverflow, err)
+			}
+			return
+		}
+		if err != nil {
+			t.Fatalf("Failed deserializing a number smaller than the prime: %v", err)
+		}
+		if *deserialized.Serialize() != serialized {
+			t.Fatalf("Expected %s == %s", deserialized.Serialize(), serialized)
+		}
+	})
+}
+
+// FuzzDeserializeOverflowBoundary probes IsOverflow around the prime: the serialized number has all of its
+// high limbs set, like the prime, with fuzzed low 128 bits and optionally one lowered high byte.
+// The seeds are the numbers around the prime that fit in 3072 bits.
+func FuzzDeserializeOverflowBoundary(f *testing.F) {
+	for _, delta := range []int64{-1 << 40, -primeDiff, -2, -1, 0, 1, 2, primeDiff - 1} {
+		boundary := bigIntToLEBytes(new(big.Int).Add(prime, big.NewInt(delta)))
+		f.Add(binary.LittleEndian.Uint64(boundary), binary.LittleEndian.Uint64(boundary[8:]), uint16(0))
+	}
+	f.Add(uint64(0), uint64(0), uint16(elementByteSize-1))
+
+	f.Fuzz(func(t *testing.T, low, next uint64, loweredByte uint16) {
+		var serialized SerializedMuHash
+		for i := range serialized {
+			serialized[i] = 0xff
+		}
+		binary.LittleEndian.PutUint64(serialized[:], low)
+		binary.LittleEndian.PutUint64(serialized[8:], next)
+		if loweredByte >= 16 && int(loweredByte) < elementByteSize {
+			serialized[loweredByte] = 0xfe
+		}
+
+		isOverflow := leBytesToBigInt(serialized[:]).Cmp(prime) >= 0
+		_, err := DeserializeMuHash(&serialized)
+		if isOverflow != (err != nil) {
+			t.Fatalf("Expected overflow: %t, got error: %v for %s", isOverflow, err, serialized)
+		}
+		if isOverflow && !errors.Is(err, ErrOverflow) {
+			t.Fatalf("Expected %s, instead found: %v", ErrOverflow, err)
+		}
+		if validateErr := serialized.Validate(); (validateErr != nil) != isOverflow {
+			t.Fatalf("Expected Validate to agree with DeserializeMuHash, got %v and %v", validateErr, err)
+		}
+	})
+}
+
+func FuzzMuHashArithmetic(f *testing.F) {
+	for _, test := range testVectors {
+		f.Add(test.dataElement)
+	}
+	f.Add(bigIntToLEBytes(new(big.Int).Sub(prime, big.NewInt(1))))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if len(data)%elementByteSize != 0 {
+			padded := make([]byte, len(data)+elementByteSize-len(data)%elementByteSize)
+			copy(padded, data)
+			data = padded
+		}
+		result := one()
+		expected := big.NewInt(1)
+		for start := 0; start < len(data); start += elementByteSize {
+			var element [elementByteSize]byte
+			copy(element[:], data[start:])
+			var elementUint uint3072
+			bytesToWordsLE(&element, &elementUint)
+			elementInt := leBytesToBigInt(element[:])
+
+			// The lowest bit of the element picks the operation.
+			if element[0]&1 == 0 {
+				result.Mul(&elementUint)
+				expected.Mul(expected, elementInt)
+			} else {
+				elementInt.Mod(elementInt, prime)
+				// Zero has no inverse.
+				if elementInt.Sign() == 0 {
+					continue
+				}
+				result.Divide(&elementUint)
+				expected.Mul(expected, elementInt.ModInverse(elementInt, prime))
+			}
+			expected.Mod(expected, prime)
+
+			var resultBytes [elementByteSize]byte
+			wordsToBytesLE(&result, &resultBytes)
+			resultInt := leBytesToBigInt(resultBytes[:])
+			if resultInt.Mod(resultInt, prime).Cmp(expected) != 0 {
+				t.Fatalf("Expected %x == %x", resultInt, expected)
+			}
+		}
+	})
+}
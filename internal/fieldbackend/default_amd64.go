@@ -0,0 +1,8 @@
+//go:build amd64 && !purego && !muhash_cgo
+// +build amd64,!purego,!muhash_cgo
+
+package fieldbackend
+
+// Default is the multiplication muhash uses unless Generic is selected, the amd64 assembly.
+// It only uses instructions that every amd64 CPU has, so there's no need to detect CPU features.
+const Default = "amd64"
@@ -0,0 +1,7 @@
+//go:build !amd64 || purego || muhash_cgo
+// +build !amd64 purego muhash_cgo
+
+package fieldbackend
+
+// Default is the multiplication muhash uses, without the assembly it's the portable Go one.
+const Default = Generic
@@ -0,0 +1,32 @@
+// Package fieldbackend holds the switch between the field multiplication implementations of muhash.
+// It's internal so that only muhash reads it and only muhashtest changes it.
+package fieldbackend
+
+import "sync/atomic"
+
+// Generic is the name of the portable Go multiplication, which is available in every build.
+const Generic = "go"
+
+// useGeneric is 1 if Mul should use the portable Go multiplication even though an accelerated one is built.
+// It's accessed atomically so that it can be switched while other goroutines are multiplying.
+var useGeneric uint32
+
+// UseGeneric returns true if the portable Go multiplication was selected instead of the default one.
+func UseGeneric() bool {
+	return atomic.LoadUint32(&useGeneric) != 0
+}
+
+// Set selects the multiplication by name, either Generic or Default. It returns false if the backend
+// isn't available in this build.
+func Set(name string) bool {
+	// Without the assembly Default is Generic, so they can't be cases of the same switch.
+	if name != Default && name != Generic {
+		return false
+	}
+	var value uint32
+	if name != Default {
+		value = 1
+	}
+	atomic.StoreUint32(&useGeneric, value)
+	return true
+}
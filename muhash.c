@@ -1,3 +1,6 @@
+//go:build muhash_cgo
+// +build muhash_cgo
+
 //
 // Created by elichai2 on 2/15/21.
 //
@@ -4,12 +4,19 @@
 package muhash
 
 import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/base64"
 	"encoding/binary"
 	"encoding/hex"
 	"github.com/pkg/errors"
 	"golang.org/x/crypto/blake2b"
 	"golang.org/x/crypto/chacha20"
-	"math/big"
+	"hash"
+	"io"
+	"runtime"
+	"sync"
 )
 
 const (
@@ -18,6 +25,12 @@ const (
 	// SerializedMuHashSize defines the length in bytes of SerializedMuHash
 	SerializedMuHashSize = elementByteSize
 
+	// The field is the integers modulo the prime 2^elementBitSize - primeDiff. The limb arithmetic, the safegcd
+	// inversion and the serialization are all derived from these two constants, so they're the parameters a fork
+	// experimenting with another prime of this form changes. They're constants rather than fields of a descriptor so
+	// that the compiler folds them into the multiplications. Besides them, a different prime needs PRIME_DIFF in
+	// uint3072_amd64.s updated, and the test-only GetInverse reference a new addition chain.
+	// TestFieldParameters checks the assumptions the arithmetic makes about them.
 	elementBitSize  = 3072
 	elementByteSize = elementBitSize / 8
 
@@ -25,13 +38,21 @@ const (
 )
 
 var (
-	// 2^3072 - 1103717, the largest 3072-bit safe prime number, is used as the modulus.
-	prime = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 3072), big.NewInt(primeDiff))
-
 	// EmptyMuHashHash is the hash of `NewMuHash().Finalize()`
 	EmptyMuHashHash = Hash{0x54, 0x4e, 0xb3, 0x14, 0x2c, 0x0, 0xf, 0xa, 0xd2, 0xc7, 0x6a, 0xc4, 0x1f, 0x42, 0x22, 0xab, 0xba, 0xba, 0xbe, 0xd8, 0x30, 0xee, 0xaf, 0xee, 0x4b, 0x6d, 0xc5, 0x6b, 0x52, 0xd5, 0xca, 0xc0}
 
-	errOverflow = errors.New("Overflow in the MuHash field")
+	// ErrOverflow is returned when a value isn't reduced modulo the MuHash prime, e.g. when deserializing.
+	// It's wrapped with context about which value overflowed, so match it with errors.Is.
+	ErrOverflow = errors.New("Overflow in the MuHash field")
+
+	// ErrDomainMismatch is returned by CombineChecked when the sets were created with different domain tags.
+	ErrDomainMismatch = errors.New("Combining MuHashes with different domains")
+
+	// ErrZeroMuHash is returned when deserializing an all-zeros MuHash, which can't be reached by adding and
+	// removing elements, so it's most likely uninitialized memory. Like ErrOverflow, match it with errors.Is.
+	ErrZeroMuHash = errors.New("A zero MuHash isn't a valid set, it's most likely uninitialized memory")
+
+	errWriterClosed = errors.New("ElementWriter is already closed")
 )
 
 // Hash is a type encapsulating the result of hashing some unknown sized data.
@@ -46,6 +67,13 @@ func (hash Hash) IsEqual(target *Hash) bool {
 	return hash == *target
 }
 
+// Matches returns true if hash is the finalized hash of the set, i.e. if the set is the one the hash commits to.
+// A hash can't be turned back into a set, so storing only the finalized hash of a checkpoint allows verifying
+// a claimed set against it, but not combining it with other sets. For that store the serialized MuHash instead.
+func (hash Hash) Matches(mu *MuHash) bool {
+	return mu.Finalize() == hash
+}
+
 // SetBytes sets the bytes which represent the hash. An error is returned if
 // the number of bytes passed in is not HashSize.
 func (hash *Hash) SetBytes(newHash []byte) error {
@@ -67,14 +95,59 @@ func (hash Hash) String() string {
 	return hex.EncodeToString(hash[:])
 }
 
+// MarshalText implements encoding.TextMarshaler, returning the Hash as a lowercase hexadecimal string.
+func (hash Hash) MarshalText() ([]byte, error) {
+	text := make([]byte, hex.EncodedLen(HashSize))
+	hex.Encode(text, hash[:])
+	return text, nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler. An error is returned if
+// the text isn't exactly HashSize hex encoded bytes.
+func (hash *Hash) UnmarshalText(text []byte) error {
+	if len(text) != hex.EncodedLen(HashSize) {
+		return errors.Errorf("invalid hash hex length got %d, expected %d", len(text),
+			hex.EncodedLen(HashSize))
+	}
+	var decoded Hash
+	_, err := hex.Decode(decoded[:], text)
+	if err != nil {
+		return errors.Wrap(err, "failed decoding hash hex")
+	}
+	*hash = decoded
+	return nil
+}
+
+// HashFromString parses a Hash from its hexadecimal string, as returned by Hash.String().
+// An error is returned if the string isn't exactly HashSize hex encoded bytes.
+func HashFromString(s string) (Hash, error) {
+	var hash Hash
+	err := hash.UnmarshalText([]byte(s))
+	if err != nil {
+		return Hash{}, err
+	}
+	return hash, nil
+}
+
 // MuHash is a type used to create a Multiplicative Hash
 // which is a rolling(homomorphic) hash that you can add and remove elements from
 // and receive the same resulting hash as-if you never hashed them.
 // Because of that the order of adding and removing elements doesn't matter.
 // Use NewMuHash to initialize a MuHash, or DeserializeMuHash to parse a MuHash.
 type MuHash struct {
-	numerator   num3072
-	denominator num3072
+	numerator   uint3072
+	denominator uint3072
+	// elementHasher is nil when the default blake2b element hasher is used.
+	elementHasher *elementHasher
+}
+
+// elementHasher is a custom way to derive the elements from their data. Either a custom hash function,
+// or the default blake2b element hasher with a domain prefix written before the data.
+type elementHasher struct {
+	hash         func(data []byte) [32]byte
+	domainPrefix []byte
+	// domain is the tag the set was created with, it shares its memory with domainPrefix.
+	domain []byte
 }
 
 // SerializedMuHash is a is a byte array representing the storage representation of a MuHash
@@ -85,6 +158,87 @@ func (serialized SerializedMuHash) String() string {
 	return hex.EncodeToString(serialized[:])
 }
 
+// Bytes returns the serialized MuHash as a slice, the slice shares its memory with the SerializedMuHash.
+func (serialized *SerializedMuHash) Bytes() []byte {
+	return serialized[:]
+}
+
+// Equal returns true if both serialized MuHashes are the same.
+// The comparison is constant time, so comparing commitments doesn't leak timing information.
+func (serialized *SerializedMuHash) Equal(other *SerializedMuHash) bool {
+	return subtle.ConstantTimeCompare(serialized[:], other[:]) == 1
+}
+
+// Validate returns an error if the serialized MuHash can't be deserialized, i.e. if it overflows the field
+// or is zero, without constructing a MuHash. It agrees exactly with DeserializeMuHash.
+func (serialized *SerializedMuHash) Validate() error {
+	var numerator uint3072
+	return serialized.toNumerator(&numerator, false)
+}
+
+// toNumerator converts the serialized MuHash into a numerator, returning ErrOverflow if it overflows the field,
+// and ErrZeroMuHash if it's zero and allowZero is false.
+func (serialized *SerializedMuHash) toNumerator(numerator *uint3072, allowZero bool) error {
+	bytesToWordsLE((*[elementByteSize]byte)(serialized), numerator)
+	if numerator.IsOverflow() {
+		return errors.Wrap(ErrOverflow, "serialized muhash exceeds the field prime")
+	}
+	if !allowZero && numerator.IsZero() {
+		return errors.Wrap(ErrZeroMuHash, "serialized muhash is all zeros")
+	}
+	return nil
+}
+
+// SetHex sets the serialized MuHash to the bytes encoded in the hexadecimal string, as returned by String().
+// It decodes directly into the array, without allocating. An error is returned if the string isn't exactly
+// SerializedMuHashSize hex encoded bytes, in which case the serialized MuHash is left unchanged.
+// Like SerializedMuHashFromBytes it doesn't check that the value is in the field, use Validate for that.
+func (serialized *SerializedMuHash) SetHex(str string) error {
+	if len(str) != hex.EncodedLen(SerializedMuHashSize) {
+		return errors.Errorf("invalid serialized muhash hex length got %d, expected %d", len(str),
+			hex.EncodedLen(SerializedMuHashSize))
+	}
+	var decoded SerializedMuHash
+	for i := range decoded {
+		high, ok := fromHexChar(str[2*i])
+		if !ok {
+			return errors.Wrap(hex.InvalidByteError(str[2*i]), "failed decoding serialized muhash hex")
+		}
+		low, ok := fromHexChar(str[2*i+1])
+		if !ok {
+			return errors.Wrap(hex.InvalidByteError(str[2*i+1]), "failed decoding serialized muhash hex")
+		}
+		decoded[i] = high<<4 | low
+	}
+	*serialized = decoded
+	return nil
+}
+
+// fromHexChar converts a hex character into its value, accepting both upper and lower case like encoding/hex.
+func fromHexChar(c byte) (byte, bool) {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0', true
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10, true
+	case 'A' <= c && c <= 'F':
+		return c - 'A' + 10, true
+	}
+	return 0, false
+}
+
+// SerializedMuHashFromBytes copies the bytes into a new SerializedMuHash.
+// An error is returned if the number of bytes passed in is not SerializedMuHashSize.
+func SerializedMuHashFromBytes(data []byte) (*SerializedMuHash, error) {
+	if len(data) != SerializedMuHashSize {
+		return nil, errors.Errorf("invalid serialized muhash length got %d, expected %d", len(data),
+			SerializedMuHashSize)
+	}
+	var serialized SerializedMuHash
+	copy(serialized[:], data)
+	return &serialized, nil
+}
+
 // String returns the MultiSet as the hexadecimal string
 func (mu MuHash) String() string {
 	return mu.Serialize().String()
@@ -92,11 +246,70 @@ func (mu MuHash) String() string {
 
 // NewMuHash return an empty initialized set.
 // when finalized it should be equal to a finalized set with all elements removed.
+// There's deliberately no shared empty set: a MuHash is mutable, so a shared pointer would be corrupted by the
+// first caller that adds to it, and creating one doesn't allocate anything besides the MuHash itself.
+// Compare against EmptyMuHashHash or use IsEmpty instead.
 func NewMuHash() *MuHash {
 	return &MuHash{
-		numerator:   oneNum3072(),
-		denominator: oneNum3072(),
+		numerator:   one(),
+		denominator: one(),
+	}
+}
+
+// NewMuHashWithHasher return an empty initialized set which derives its elements using the given hash function
+// instead of blake2b. The hash is expanded into an element the same way a blake2b hash is.
+// The hash function is fixed for the lifetime of the set (it survives Reset and Clone), and sets using different
+// hash functions must never be combined.
+func NewMuHashWithHasher(hasher func(data []byte) [32]byte) *MuHash {
+	mu := NewMuHash()
+	mu.elementHasher = &elementHasher{hash: hasher}
+	return mu
+}
+
+// NewMuHashWithDomain return an empty initialized set whose elements are derived from the domain tag and their data,
+// so that the same data results in different elements in sets with different tags. This allows committing to
+// different kinds of objects in separate sets without their elements colliding.
+// The tag is length prefixed and written into the blake2b element hasher before the data.
+// An empty tag results in the same elements as NewMuHash. Like with NewMuHashWithHasher, the tag is fixed for the
+// lifetime of the set, and sets with different tags must never be combined.
+func NewMuHashWithDomain(tag []byte) *MuHash {
+	mu := NewMuHash()
+	if len(tag) == 0 {
+		return mu
+	}
+	domainPrefix := make([]byte, binary.MaxVarintLen64, binary.MaxVarintLen64+len(tag))
+	prefixLen := binary.PutUvarint(domainPrefix, uint64(len(tag)))
+	domainPrefix = append(domainPrefix[:prefixLen], tag...)
+	mu.elementHasher = &elementHasher{domainPrefix: domainPrefix, domain: domainPrefix[prefixLen:]}
+	return mu
+}
+
+// Domain returns the tag the set was created with by NewMuHashWithDomain or DeserializeMuHashWithDomain,
+// or nil if it has no domain. The domain isn't part of the serialized MuHash.
+func (mu *MuHash) Domain() []byte {
+	if mu.elementHasher == nil || mu.elementHasher.domain == nil {
+		return nil
 	}
+	return append([]byte(nil), mu.elementHasher.domain...)
+}
+
+// checkDomain returns ErrDomainMismatch if the sets have different domains. Sets without a domain can only be
+// combined with each other, since a set with a domain derives different elements from the same data.
+func (mu *MuHash) checkDomain(other *MuHash) error {
+	if mu.elementHasher == other.elementHasher {
+		return nil
+	}
+	var domain, otherDomain []byte
+	if mu.elementHasher != nil {
+		domain = mu.elementHasher.domain
+	}
+	if other.elementHasher != nil {
+		otherDomain = other.elementHasher.domain
+	}
+	if !bytes.Equal(domain, otherDomain) {
+		return errors.Wrapf(ErrDomainMismatch, "domain %q can't be combined with domain %q", domain, otherDomain)
+	}
+	return nil
 }
 
 // Reset clears the muhash from all data. Equivalent to creating a new empty set
@@ -105,128 +318,969 @@ func (mu *MuHash) Reset() {
 	mu.denominator.SetToOne()
 }
 
-// Clone the muhash to create a new one
+// IsEmpty returns true if the muhash is equal to an empty set, i.e. if every element that was added was also removed.
+// This is cheaper than comparing the finalized hash to EmptyMuHashHash, since it requires neither
+// a modular inversion nor hashing.
+func (mu *MuHash) IsEmpty() bool {
+	// numerator/denominator == 1 iff numerator == denominator (mod p), as long as they aren't zero.
+	numerator, denominator := mu.numerator, mu.denominator
+	if numerator.IsOverflow() {
+		numerator.FullReduce()
+	}
+	if denominator.IsOverflow() {
+		denominator.FullReduce()
+	}
+	return numerator == denominator && !numerator.IsZero()
+}
+
+// DenominatorIsOne returns true if the denominator of the muhash is one, which is the case if nothing was removed
+// from it since it was created, reset, deserialized or normalized.
+func (mu *MuHash) DenominatorIsOne() bool {
+	denominator := mu.denominator
+	if denominator.IsOverflow() {
+		denominator.FullReduce()
+	}
+	return denominator == one()
+}
+
+// NeedsNormalize returns true if serializing or finalizing the muhash requires a modular inversion, which is the
+// case if its denominator isn't one. The inversion costs the same no matter how many elements were removed.
+func (mu *MuHash) NeedsNormalize() bool {
+	return !mu.DenominatorIsOne()
+}
+
+// Clone the muhash to create a new one. The clone is independent of the original, changing one doesn't affect the other.
 func (mu MuHash) Clone() *MuHash {
 	return &mu
 }
 
+// CloneInto copies the muhash into dst without allocating, overwriting whatever dst held.
+// Like with Clone, dst is independent of the original.
+func (mu *MuHash) CloneInto(dst *MuHash) {
+	// The numbers are arrays so they're copied.
+	*dst = *mu
+}
+
 // Add hashes the data and adds it to the muhash.
 // Supports arbitrary length data (subject to the underlying hash function(Blake2b) limits)
+// Empty data (including nil) is a valid element like any other: it's the element derived from the
+// keyed blake2b hash of zero bytes, so adding it changes the set, and Add(nil) is the same as Add([]byte{}).
 func (mu *MuHash) Add(data []byte) {
-	var element num3072
-	dataToElement(data, &element)
+	var element uint3072
+	mu.dataToElement(data, &element)
 	mu.addElement(&element)
 }
 
-func (mu *MuHash) addElement(element *num3072) {
+// AddFromChannel adds every element received from the channel to the muhash, until the channel is closed,
+// in which case it returns nil, or until the context is done, in which case it returns ctx.Err().
+// The elements received before the context was done are kept in the muhash.
+// Like Add it modifies the muhash, so the goroutine calling it must be its only user until it returns.
+func (mu *MuHash) AddFromChannel(ctx context.Context, ch <-chan []byte) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case data, ok := <-ch:
+			if !ok {
+				return nil
+			}
+			mu.Add(data)
+		}
+	}
+}
+
+// AddUint64 adds the integer to the muhash as an element.
+// The integer is encoded as 8 little endian bytes, so AddUint64(x) is the same as Add with those 8 bytes.
+// This encoding is part of the API and won't change.
+func (mu *MuHash) AddUint64(x uint64) {
+	var data [8]byte
+	binary.LittleEndian.PutUint64(data[:], x)
+	mu.Add(data[:])
+}
+
+// RemoveUint64 removes the integer from the muhash, it's encoded the same way as in AddUint64.
+func (mu *MuHash) RemoveUint64(x uint64) {
+	var data [8]byte
+	binary.LittleEndian.PutUint64(data[:], x)
+	mu.Remove(data[:])
+}
+
+// AddKeyValue adds a key/value pair to the muhash as a single element, e.g. an entry of a map being committed to.
+// The element is encoded as len(key) || key || len(value) || value, where each length is 8 little endian bytes,
+// so that moving bytes between the key and the value results in a different element.
+// AddKeyValue(key, value) is the same as Add with that encoding. This encoding is part of the API and won't change.
+func (mu *MuHash) AddKeyValue(key, value []byte) {
+	mu.Add(encodeKeyValue(key, value))
+}
+
+// RemoveKeyValue removes a key/value pair from the muhash, it's encoded the same way as in AddKeyValue.
+func (mu *MuHash) RemoveKeyValue(key, value []byte) {
+	mu.Remove(encodeKeyValue(key, value))
+}
+
+func encodeKeyValue(key, value []byte) []byte {
+	encoded := make([]byte, 8+len(key)+8+len(value))
+	binary.LittleEndian.PutUint64(encoded, uint64(len(key)))
+	copy(encoded[8:], key)
+	binary.LittleEndian.PutUint64(encoded[8+len(key):], uint64(len(value)))
+	copy(encoded[8+len(key)+8:], value)
+	return encoded
+}
+
+// AddHashed adds an element derived directly from a 32 byte digest that the caller already computed,
+// skipping the blake2b hashing of Add.
+// This results in a different element than Add(digest[:]), which hashes the digest again, and it ignores
+// the element hasher of the muhash. It's meant for callers who manage their own domain separation, since
+// nothing separates these elements from the ones of Add.
+func (mu *MuHash) AddHashed(digest [32]byte) {
+	var element uint3072
+	hashed := Hash(digest)
+	hashToElement(&hashed, &element)
+	mu.addElement(&element)
+}
+
+// RemoveHashed removes an element that was added with AddHashed.
+func (mu *MuHash) RemoveHashed(digest [32]byte) {
+	var element uint3072
+	hashed := Hash(digest)
+	hashToElement(&hashed, &element)
+	mu.removeElement(&element)
+}
+
+// ElementWriter returns an io.WriteCloser that hashes all the data written to it as a single element,
+// and adds that element to the muhash on Close.
+// Writing the data in chunks results in the same element as calling Add with the whole data at once.
+// If the muhash uses a custom hash function the data is buffered until Close, because it can't be streamed.
+func (mu *MuHash) ElementWriter() io.WriteCloser {
+	writer := &elementWriter{mu: mu}
+	if mu.elementHasher == nil || mu.elementHasher.hash == nil {
+		writer.hasher = newElementHasher()
+		if mu.elementHasher != nil {
+			writer.hasher.Write(mu.elementHasher.domainPrefix)
+		}
+	}
+	return writer
+}
+
+type elementWriter struct {
+	mu *MuHash
+	// hasher is used with the default element hasher, otherwise the data is accumulated.
+	hasher hash.Hash
+	data   []byte
+	closed bool
+}
+
+func (w *elementWriter) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, errWriterClosed
+	}
+	if w.hasher != nil {
+		return w.hasher.Write(p)
+	}
+	w.data = append(w.data, p...)
+	return len(p), nil
+}
+
+func (w *elementWriter) Close() error {
+	if w.closed {
+		return errWriterClosed
+	}
+	var element uint3072
+	if w.hasher != nil {
+		hasherToElement(w.hasher, &element)
+	} else {
+		w.mu.dataToElement(w.data, &element)
+	}
+	w.mu.addElement(&element)
+	w.closed = true
+	w.hasher = nil
+	w.data = nil
+	return nil
+}
+
+// addElement and removeElement don't special-case a zero element (or one equal to the prime), it zeroes the set
+// permanently like in other MuHash implementations. Such an element can't be derived from data, since that would require
+// a blake2b hash whose 384 byte chacha20 key stream is a multiple of the prime, which is as hard as breaking chacha20.
+func (mu *MuHash) addElement(element *uint3072) {
 	mu.numerator.Mul(element)
 }
 
 // Remove hashes the data and removes it from the multiset.
 // Supports arbitrary length data (subject to the underlying hash function(Blake2b) limits)
+// The data doesn't have to be in the multiset, multiplicities can be negative: removing data that was never added
+// results in a valid set that can be serialized and finalized like any other, and adding the data later cancels
+// the removal, returning to the previous hash. This holds regardless of normalizing or serializing in between.
 func (mu *MuHash) Remove(data []byte) {
-	var element num3072
-	dataToElement(data, &element)
+	var element uint3072
+	mu.dataToElement(data, &element)
 	mu.removeElement(&element)
 }
 
-func (mu *MuHash) removeElement(element *num3072) {
+func (mu *MuHash) removeElement(element *uint3072) {
 	mu.denominator.Mul(element)
 }
 
 // Combine will add the MuHash together. Equivalent to manually adding all the data elements
-// from one set to the other.
+// from one set to the other. Both sets must use the same element hasher.
+// Combine panics if the sets were created with different domain tags (see NewMuHashWithDomain),
+// use CombineChecked to get an error instead. Custom hash functions can't be compared, so they aren't checked.
+// Combining a set with itself (`mu.Combine(mu)`) is well-defined: it squares the numerator and denominator,
+// i.e. it doubles the multiplicity of every element, exactly like combining it with a clone of itself.
 func (mu *MuHash) Combine(other *MuHash) {
+	if err := mu.checkDomain(other); err != nil {
+		panic(err)
+	}
 	mu.numerator.Mul(&other.numerator)
 	mu.denominator.Mul(&other.denominator)
 }
 
+// Double combines the set with itself, doubling the multiplicity of every element. Equivalent to `mu.Combine(mu)`.
+// It squares the numerator and denominator with Mul rather than Square: the dedicated squaring saves about half
+// of the limb multiplications, but it's still slower than the assembly Mul on amd64, and than the generic Mul
+// elsewhere, because of its extra carry handling (see BenchmarkMuHash_Double).
+func (mu *MuHash) Double() {
+	mu.Combine(mu)
+}
+
+// CombineChecked is like Combine, but first verifies that the numerator and denominator of the other set are fully
+// reduced, which is always the case for sets built with this package's API. It returns ErrOverflow otherwise,
+// and ErrDomainMismatch if the sets have different domains, without modifying the set.
+// Use it when the other set comes from an untrusted source, Combine is cheaper.
+func (mu *MuHash) CombineChecked(other *MuHash) error {
+	if err := mu.checkDomain(other); err != nil {
+		return err
+	}
+	if other.numerator.IsOverflow() || other.denominator.IsOverflow() {
+		return errors.Wrap(ErrOverflow, "numerator or denominator exceeds the field prime")
+	}
+	mu.Combine(other)
+	return nil
+}
+
+// Diff returns a new MuHash that is equal to this set with all the elements of the other set removed,
+// so combining the result with other results in this set again. Neither set is modified.
+// Both sets must use the same element hasher. Like Combine, Diff panics if the sets have different domains.
+func (mu *MuHash) Diff(other *MuHash) *MuHash {
+	if err := mu.checkDomain(other); err != nil {
+		panic(err)
+	}
+	diff := &MuHash{
+		numerator:     mu.numerator,
+		denominator:   mu.denominator,
+		elementHasher: mu.elementHasher,
+	}
+	diff.numerator.Mul(&other.denominator)
+	diff.denominator.Mul(&other.numerator)
+	return diff
+}
+
+// DeltaSerialized returns the MuHash that transforms one serialized MuHash into another, i.e. combining the
+// deserialized `from` with the result finalizes to `to`. It's the Diff of the deserialized sets,
+// so the elements that were added and removed between two stored commitments can be reasoned about as a set.
+// An error is returned if either of them overflows the field.
+// The domain isn't part of the serialization, so the result has no domain. For sets created with
+// NewMuHashWithDomain, deserialize both with DeserializeMuHashWithDomain and use Diff instead.
+func DeltaSerialized(from, to *SerializedMuHash) (*MuHash, error) {
+	fromMuHash, err := DeserializeMuHash(from)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed deserializing from")
+	}
+	toMuHash, err := DeserializeMuHash(to)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed deserializing to")
+	}
+	return toMuHash.Diff(fromMuHash), nil
+}
+
+// CombineAll will add all the other MuHashes to this one. Equivalent to calling Combine with each of them.
+func (mu *MuHash) CombineAll(others ...*MuHash) {
+	for _, other := range others {
+		mu.Combine(other)
+	}
+}
+
+// CombineSerialized will add the serialized MuHash to this one, without deserializing it into a new MuHash.
+// Equivalent to calling Combine with the result of DeserializeMuHash.
+// The set isn't modified if the serialized MuHash overflows the field or is zero.
+func (mu *MuHash) CombineSerialized(serialized *SerializedMuHash) error {
+	numerator := uint3072{}
+	err := serialized.toNumerator(&numerator, false)
+	if err != nil {
+		return err
+	}
+	mu.numerator.Mul(&numerator)
+	return nil
+}
+
+// RemoveSerialized will remove the serialized MuHash from this one, the inverse of CombineSerialized,
+// without deserializing it into a new MuHash. Equivalent to calling Diff with the result of DeserializeMuHash.
+// The set isn't modified if the serialized MuHash overflows the field or is zero.
+func (mu *MuHash) RemoveSerialized(serialized *SerializedMuHash) error {
+	numerator := uint3072{}
+	err := serialized.toNumerator(&numerator, false)
+	if err != nil {
+		return err
+	}
+	mu.denominator.Mul(&numerator)
+	return nil
+}
+
+// minSetsPerWorker is the least number of sets each ParallelCombine and NormalizeAll worker gets,
+// below that the goroutines cost more than the multiplications they save.
+const minSetsPerWorker = 16
+
+// ParallelCombine returns a new MuHash that is the combination of all the sets, the same as calling
+// CombineAll on a new MuHash, but splits the multiplications between `workers` goroutines.
+// If workers isn't positive runtime.GOMAXPROCS(0) is used. Small inputs are combined serially.
+// The sets aren't modified, and must all use the same element hasher.
+// Like Combine it panics if the sets have different domains. The domains are checked before any
+// goroutine starts, so the panic happens on the caller's goroutine and can be recovered.
+func ParallelCombine(sets []*MuHash, workers int) *MuHash {
+	result := NewMuHash()
+	if len(sets) == 0 {
+		return result
+	}
+	result.elementHasher = sets[0].elementHasher
+	for _, set := range sets[1:] {
+		if err := result.checkDomain(set); err != nil {
+			panic(err)
+		}
+	}
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if workers > len(sets)/minSetsPerWorker {
+		workers = len(sets) / minSetsPerWorker
+	}
+	if workers <= 1 {
+		result.CombineAll(sets...)
+		return result
+	}
+
+	partials := make([]MuHash, workers)
+	var wg sync.WaitGroup
+	for i := range partials {
+		start := i * len(sets) / workers
+		end := (i + 1) * len(sets) / workers
+		wg.Add(1)
+		go func(partial *MuHash, chunk []*MuHash) {
+			defer wg.Done()
+			partial.Reset()
+			partial.elementHasher = result.elementHasher
+			partial.CombineAll(chunk...)
+		}(&partials[i], sets[start:end])
+	}
+	wg.Wait()
+	for i := range partials {
+		result.Combine(&partials[i])
+	}
+	return result
+}
+
 // Finalize will return a hash(Blake2b) of the multiset.
 // Because the returned value is a hash of a multiset you cannot "Un-Finalize" it.
 // If this is meant for storage then Serialize should be used instead.
 func (mu *MuHash) normalize() {
+	if mu.DenominatorIsOne() {
+		// Dividing by one only reduces the numerator, so the modular inversion can be skipped.
+		if mu.numerator.IsOverflow() {
+			mu.numerator.FullReduce()
+		}
+		mu.denominator.SetToOne()
+		return
+	}
 	mu.numerator.Divide(&mu.denominator)
 	mu.denominator.SetToOne()
 }
 
+// Normalize divides the numerator by the denominator, leaving a denominator of one. It doesn't change the set,
+// but it moves the modular inversion that Serialize and Finalize would otherwise need out of their path, so it can be
+// done ahead of time, e.g. when idle. Normalizing an already normalized set is cheap and has no effect.
+// Unlike Serialize and Finalize, Normalize modifies the MuHash, so it's not safe to call concurrently with other methods.
+func (mu *MuHash) Normalize() {
+	mu.normalize()
+}
+
+// NormalizeBatch normalizes all the sets using a single modular inversion (Montgomery's trick),
+// instead of one per set. This makes later calls to Serialize and Finalize on these sets cheaper.
+func NormalizeBatch(sets []*MuHash) {
+	inverses := make([]uint3072, len(sets))
+	for i, set := range sets {
+		inverses[i] = set.denominator
+	}
+	batchInverse(inverses)
+	for i, set := range sets {
+		if set.numerator.IsOverflow() {
+			set.numerator.FullReduce()
+		}
+		set.numerator.Mul(&inverses[i])
+		if set.numerator.IsOverflow() {
+			set.numerator.FullReduce()
+		}
+		set.denominator.SetToOne()
+	}
+}
+
+// NormalizeAll normalizes all the sets in place, the same as calling Normalize on each of them, but splits them
+// between `workers` goroutines, each of which normalizes its share with NormalizeBatch's single modular inversion.
+// If workers isn't positive runtime.GOMAXPROCS(0) is used. Small inputs are normalized serially.
+// The sets must not be used concurrently with NormalizeAll.
+func NormalizeAll(sets []*MuHash, workers int) {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if workers > len(sets)/minSetsPerWorker {
+		workers = len(sets) / minSetsPerWorker
+	}
+	if workers <= 1 {
+		NormalizeBatch(sets)
+		return
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		start := i * len(sets) / workers
+		end := (i + 1) * len(sets) / workers
+		wg.Add(1)
+		go func(chunk []*MuHash) {
+			defer wg.Done()
+			NormalizeBatch(chunk)
+		}(sets[start:end])
+	}
+	wg.Wait()
+}
+
 // Serialize returns a serialized version of the MuHash. This is the only right way to serialize a multiset for storage.
 // This MuHash is not finalized, this is meant for storage.
+// Serialize doesn't modify the MuHash, so it's safe to call concurrently with other non-modifying methods.
 func (mu *MuHash) Serialize() *SerializedMuHash {
 	var out SerializedMuHash
-	mu.serializeInner(&out)
+	mu.SerializeToArray(&out)
 	return &out
 }
 
-func (mu *MuHash) serializeInner(out *SerializedMuHash) {
-	mu.normalize()
-	b := mu.numerator
-	for i := range b.limbs {
-		switch wordSize {
-		case 64:
-			binary.LittleEndian.PutUint64(out[i*wordSizeInBytes:], uint64(b.limbs[i]))
-		case 32:
-			binary.LittleEndian.PutUint32(out[i*wordSizeInBytes:], uint32(b.limbs[i]))
-		default:
-			panic("Only 32/64 bits machines are supported")
-		}
+// SerializeToArray writes the same serialization as Serialize into out, without allocating it,
+// e.g. directly into memory mapped storage.
+// Like Serialize it doesn't modify the MuHash, so it's safe to call concurrently with other non-modifying methods.
+func (mu *MuHash) SerializeToArray(out *SerializedMuHash) {
+	// Normalize a copy so that serializing won't modify the receiver.
+	normalized := MuHash{numerator: mu.numerator, denominator: mu.denominator}
+	normalized.normalize()
+	wordsToBytesLE(&normalized.numerator, (*[elementByteSize]byte)(out))
+}
+
+// DeserializeMuHashWithHasher will deserialize the MuHash that `Serialize()` serialized,
+// for a MuHash that was created with NewMuHashWithHasher.
+func DeserializeMuHashWithHasher(serialized *SerializedMuHash, hasher func(data []byte) [32]byte) (*MuHash, error) {
+	mu, err := DeserializeMuHash(serialized)
+	if err != nil {
+		return nil, err
+	}
+	mu.elementHasher = &elementHasher{hash: hasher}
+	return mu, nil
+}
+
+// DeserializeMuHashWithDomain will deserialize the MuHash that `Serialize()` serialized,
+// for a MuHash that was created with NewMuHashWithDomain.
+func DeserializeMuHashWithDomain(serialized *SerializedMuHash, tag []byte) (*MuHash, error) {
+	mu := NewMuHashWithDomain(tag)
+	err := mu.SetFromSerialized(serialized)
+	if err != nil {
+		return nil, err
 	}
+	return mu, nil
 }
 
 // DeserializeMuHash will deserialize the MuHash that `Serialize()` serialized.
+// An error is returned if it overflows the field (ErrOverflow), or if it's all zeros (ErrZeroMuHash):
+// the zero MuHash can't be reached by adding and removing elements, so an all-zeros serialization
+// is most likely uninitialized memory.
+// Use DeserializeMuHashAllowZero to accept it.
 func DeserializeMuHash(serialized *SerializedMuHash) (*MuHash, error) {
-	numerator := num3072{}
-	bytesToWordsLE((*[elementByteSize]byte)(serialized), &numerator.limbs)
-	if numerator.IsOverflow() {
-		return nil, errOverflow
+	mu := NewMuHash()
+	err := mu.SetFromSerialized(serialized)
+	if err != nil {
+		return nil, err
+	}
+	return mu, nil
+}
+
+// DeserializeMuHashAllowZero is like DeserializeMuHash, but accepts an all-zeros serialization, which results in
+// the zero MuHash, the absorbing element that no element can be added to or removed from.
+// Use it only when zero is a legitimate stored value, DeserializeMuHash treats it as uninitialized memory.
+func DeserializeMuHashAllowZero(serialized *SerializedMuHash) (*MuHash, error) {
+	mu := NewMuHash()
+	err := mu.setFromSerialized(serialized, true)
+	if err != nil {
+		return nil, err
 	}
+	return mu, nil
+}
 
-	return &MuHash{
-		numerator:   numerator,
-		denominator: oneNum3072(),
-	}, nil
+// SetFromSerialized sets the muhash to the MuHash that `Serialize()` serialized, without allocating.
+// The element hasher of the receiver is kept. On error (ErrOverflow or ErrZeroMuHash, like DeserializeMuHash)
+// the receiver is left unchanged.
+func (mu *MuHash) SetFromSerialized(serialized *SerializedMuHash) error {
+	return mu.setFromSerialized(serialized, false)
+}
+
+func (mu *MuHash) setFromSerialized(serialized *SerializedMuHash, allowZero bool) error {
+	numerator := uint3072{}
+	err := serialized.toNumerator(&numerator, allowZero)
+	if err != nil {
+		return err
+	}
+
+	mu.numerator = numerator
+	mu.denominator.SetToOne()
+	return nil
+}
+
+// DeserializeMuHashFromSlice will deserialize the MuHash that `Serialize()` serialized from a byte slice.
+// An error is returned if the number of bytes passed in is not SerializedMuHashSize.
+func DeserializeMuHashFromSlice(data []byte) (*MuHash, error) {
+	serialized, err := SerializedMuHashFromBytes(data)
+	if err != nil {
+		return nil, err
+	}
+	return DeserializeMuHash(serialized)
+}
+
+// AppendSerialized appends the serialized MuHash to dst and returns the extended slice.
+// The appended bytes are identical to the ones returned by Serialize, but it doesn't allocate
+// if dst has enough capacity.
+func (mu *MuHash) AppendSerialized(dst []byte) []byte {
+	var serialized SerializedMuHash
+	mu.SerializeToArray(&serialized)
+	return append(dst, serialized[:]...)
+}
+
+// SerializedSize returns the number of bytes n serialized MuHashes take, n * SerializedMuHashSize.
+// It panics if n is negative or if the size doesn't fit in an int, instead of silently overflowing.
+func SerializedSize(n int) int {
+	const maxInt = int(^uint(0) >> 1)
+	if n < 0 || n > maxInt/SerializedMuHashSize {
+		panic(errors.Errorf("the serialized size of %d MuHashes doesn't fit in an int", n))
+	}
+	return n * SerializedMuHashSize
+}
+
+// SerializeMany serializes all the sets contiguously into a single slice of SerializedSize(len(sets)) bytes,
+// allocating only the slice. The serialization of sets[i] starts at i * SerializedMuHashSize, and is identical
+// to the one returned by Serialize. Like Serialize it doesn't modify the sets.
+func SerializeMany(sets []*MuHash) []byte {
+	serialized := make([]byte, 0, SerializedSize(len(sets)))
+	for _, set := range sets {
+		serialized = set.AppendSerialized(serialized)
+	}
+	return serialized
+}
+
+// DeserializeMany deserializes the sets that SerializeMany serialized, i.e. concatenated serialized MuHashes.
+// An error is returned if the length of the data isn't a multiple of SerializedMuHashSize, or on the first
+// serialized MuHash that can't be deserialized, with its index. Use ForEachSerialized to avoid holding all the sets
+// in memory at once.
+func DeserializeMany(data []byte) ([]*MuHash, error) {
+	sets := make([]*MuHash, 0, len(data)/SerializedMuHashSize)
+	err := ForEachSerialized(data, func(mu *MuHash) error {
+		sets = append(sets, mu.Clone())
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return sets, nil
+}
+
+// ForEachSerialized deserializes the concatenated serialized MuHashes one at a time, and calls fn with each of them
+// in order. It stops on the first error, either from deserializing (with the index of the serialized MuHash)
+// or returned by fn, and returns it. The data is validated like in DeserializeMany.
+// To avoid allocating, the same MuHash is reused for every call, so fn must Clone it to keep it after returning.
+func ForEachSerialized(data []byte, fn func(mu *MuHash) error) error {
+	if len(data)%SerializedMuHashSize != 0 {
+		return errors.Errorf("invalid serialized muhashes length %d, expected a multiple of %d", len(data),
+			SerializedMuHashSize)
+	}
+	mu := NewMuHash()
+	var serialized SerializedMuHash
+	for i := 0; i < len(data)/SerializedMuHashSize; i++ {
+		copy(serialized[:], data[i*SerializedMuHashSize:])
+		err := mu.SetFromSerialized(&serialized)
+		if err != nil {
+			return errors.Wrapf(err, "failed deserializing muhash #%d", i)
+		}
+		err = fn(mu)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ToProtoBytes returns the serialized MuHash as a new slice, for protocols (like protobuf) that carry it as bytes.
+func (mu *MuHash) ToProtoBytes() []byte {
+	return mu.Serialize().Bytes()
+}
+
+// MuHashFromProtoBytes deserializes a MuHash from the bytes returned by ToProtoBytes.
+// An error is returned if the length isn't SerializedMuHashSize, or if it overflows the field.
+func MuHashFromProtoBytes(b []byte) (*MuHash, error) {
+	return DeserializeMuHashFromSlice(b)
+}
+
+// MuHashFromHex parses a MuHash from the hexadecimal string of its serialization, as returned by MuHash.String().
+// An error is returned if the string isn't exactly SerializedMuHashSize hex encoded bytes, or if it overflows the field.
+func MuHashFromHex(s string) (*MuHash, error) {
+	var serialized SerializedMuHash
+	err := serialized.SetHex(s)
+	if err != nil {
+		return nil, err
+	}
+	return DeserializeMuHash(&serialized)
+}
+
+// Base64 returns the serialization of the MuHash as a standard padded base64 string, which is shorter than String().
+func (mu *MuHash) Base64() string {
+	var serialized SerializedMuHash
+	mu.SerializeToArray(&serialized)
+	return base64.StdEncoding.EncodeToString(serialized[:])
+}
+
+// MuHashFromBase64 parses a MuHash from the standard padded base64 string of its serialization, as returned by
+// MuHash.Base64(). An error is returned if the string isn't exactly SerializedMuHashSize base64 encoded bytes,
+// or if it overflows the field.
+func MuHashFromBase64(s string) (*MuHash, error) {
+	if len(s) != base64.StdEncoding.EncodedLen(SerializedMuHashSize) {
+		return nil, errors.Errorf("invalid muhash base64 length got %d, expected %d", len(s),
+			base64.StdEncoding.EncodedLen(SerializedMuHashSize))
+	}
+	data, err := base64.StdEncoding.Strict().DecodeString(s)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed decoding muhash base64")
+	}
+	return DeserializeMuHashFromSlice(data)
+}
+
+// SerializeRaw returns the numerator followed by the denominator of the MuHash as they are, each as 384 little endian
+// bytes. It's meant for debugging and diagnostics, e.g. comparing the exact state of two implementations that disagree.
+// It's not the storage format: it isn't normalized, so equal sets can have different raw serializations. Use Serialize for storage.
+func (mu *MuHash) SerializeRaw() [2 * elementByteSize]byte {
+	var numerator, denominator [elementByteSize]byte
+	wordsToBytesLE(&mu.numerator, &numerator)
+	wordsToBytesLE(&mu.denominator, &denominator)
+	var raw [2 * elementByteSize]byte
+	copy(raw[:elementByteSize], numerator[:])
+	copy(raw[elementByteSize:], denominator[:])
+	return raw
+}
+
+// DeserializeRaw returns the MuHash that SerializeRaw serialized, using the default element hasher.
+// Like SerializeRaw this is a debugging format, and the numerator and denominator are used as they are
+// without any validation.
+func DeserializeRaw(raw *[2 * elementByteSize]byte) *MuHash {
+	mu := NewMuHash()
+	var numerator, denominator [elementByteSize]byte
+	copy(numerator[:], raw[:elementByteSize])
+	copy(denominator[:], raw[elementByteSize:])
+	bytesToWordsLE(&numerator, &mu.numerator)
+	bytesToWordsLE(&denominator, &mu.denominator)
+	return mu
+}
+
+// SerializeBigEndian returns the same serialization as Serialize, but as a big endian number,
+// for interoperating with systems that expect field elements in big endian.
+// Serialize remains the storage format.
+func (mu *MuHash) SerializeBigEndian() [SerializedMuHashSize]byte {
+	var serialized SerializedMuHash
+	mu.SerializeToArray(&serialized)
+	var out [SerializedMuHashSize]byte
+	for i := range serialized {
+		out[len(out)-1-i] = serialized[i]
+	}
+	return out
+}
+
+// NormalizedLimbs returns the normalized value of the MuHash as 64 bit little endian limbs, the same on 32 and 64 bit
+// machines, e.g. for dumping the state of two nodes that disagree. It's a diagnostic accessor, Serialize remains
+// the storage format. Like Serialize it normalizes a copy, so the MuHash isn't modified.
+func (mu *MuHash) NormalizedLimbs() [elementByteSize / 8]uint64 {
+	normalized := MuHash{numerator: mu.numerator, denominator: mu.denominator}
+	normalized.normalize()
+	return toUint64s(&normalized.numerator)
+}
+
+// DeserializeMuHashBigEndian will deserialize the MuHash that `SerializeBigEndian()` serialized.
+// An error is returned if it overflows the field.
+func DeserializeMuHashBigEndian(serialized *[SerializedMuHashSize]byte) (*MuHash, error) {
+	var littleEndian SerializedMuHash
+	for i := range serialized {
+		littleEndian[len(littleEndian)-1-i] = serialized[i]
+	}
+	return DeserializeMuHash(&littleEndian)
+}
+
+// WriteTo writes the serialized MuHash into w, implementing io.WriterTo.
+// The written bytes are identical to the ones returned by Serialize.
+func (mu *MuHash) WriteTo(w io.Writer) (int64, error) {
+	var serialized SerializedMuHash
+	mu.SerializeToArray(&serialized)
+	n, err := w.Write(serialized[:])
+	return int64(n), err
+}
+
+// ReadMuHash reads a single serialized MuHash from r and deserializes it, like DeserializeMuHash.
+func ReadMuHash(r io.Reader) (*MuHash, error) {
+	var serialized SerializedMuHash
+	_, err := io.ReadFull(r, serialized[:])
+	if err != nil {
+		return nil, err
+	}
+	return DeserializeMuHash(&serialized)
+}
+
+// GobEncode implements gob.GobEncoder, encoding the MuHash as its serialization.
+func (mu *MuHash) GobEncode() ([]byte, error) {
+	serialized := mu.Serialize()
+	return serialized[:], nil
+}
+
+// GobDecode implements gob.GobDecoder, decoding a MuHash encoded by GobEncode.
+// The element hasher of the receiver is kept, since it can't be encoded.
+func (mu *MuHash) GobDecode(data []byte) error {
+	decoded, err := DeserializeMuHashFromSlice(data)
+	if err != nil {
+		return err
+	}
+	mu.numerator = decoded.numerator
+	mu.denominator = decoded.denominator
+	return nil
 }
 
 // Finalize will return a hash(blake2b) of the multiset.
 // Because the returned value is a hash of a multiset you cannot "Un-Finalize" it.
 // If this is meant for storage then Serialize should be used instead.
+// Finalize doesn't modify the MuHash, so it's safe to call concurrently with other non-modifying methods.
+// Use CachedMuHash to avoid rehashing a set that didn't change.
 func (mu *MuHash) Finalize() Hash {
 	blake, err := blake2b.New256([]byte("MuHashFinalize"))
 	if err != nil {
 		panic(errors.Wrap(err, "this should never happen. MuHashFinalize is less than 64 bytes"))
 	}
 	var serialized SerializedMuHash
-	mu.serializeInner(&serialized)
+	mu.SerializeToArray(&serialized)
+	var res Hash
+	blake.Write(serialized[:])
+	blake.Sum(res[:0])
+	return res
+}
+
+// Verify returns true if the finalized hash of the muhash is equal to expected.
+// Like Finalize it doesn't modify the MuHash, so it's safe to call concurrently with other non-modifying methods.
+func (mu *MuHash) Verify(expected Hash) bool {
+	finalized := mu.Finalize()
+	return finalized.IsEqual(&expected)
+}
+
+// FinalizeWithCount returns a hash(Blake2b) of the multiset together with a count of its elements, for protocols
+// that commit to the number of elements as well. It hashes the same serialization as Finalize, followed by the count
+// as 8 little endian bytes, so sets with equal numerators but different counts have different hashes, and the
+// hashes never equal the ones of Finalize. The count isn't tracked by the MuHash, it's up to the caller.
+// Finalize is unaffected.
+func (mu *MuHash) FinalizeWithCount(count uint64) Hash {
+	blake, err := blake2b.New256([]byte("MuHashFinalize"))
+	if err != nil {
+		panic(errors.Wrap(err, "this should never happen. MuHashFinalize is less than 64 bytes"))
+	}
+	var serialized SerializedMuHash
+	mu.SerializeToArray(&serialized)
+	var countBytes [8]byte
+	binary.LittleEndian.PutUint64(countBytes[:], count)
 	var res Hash
 	blake.Write(serialized[:])
+	blake.Write(countBytes[:])
 	blake.Sum(res[:0])
 	return res
 }
 
-func dataToElement(data []byte, out *num3072) {
-	var zeros12 [12]byte
+// FinalizeInto writes the finalized hash of the muhash into dst. Equivalent to Finalize.
+func (mu *MuHash) FinalizeInto(dst *[32]byte) {
+	*dst = mu.Finalize()
+}
+
+// Sum appends the finalized hash of the muhash to b and returns the resulting slice,
+// in the shape of hash.Hash's Sum so it can be used by generic hashing code. Finalize is the preferred API.
+func (mu *MuHash) Sum(b []byte) []byte {
+	finalized := mu.Finalize()
+	return append(b, finalized[:]...)
+}
+
+// ElementBytes returns the field element that Add derives from the data (with the default blake2b element hasher),
+// fully reduced and serialized as 384 little endian bytes. It's meant for debugging, e.g. comparing the element
+// derivation of different implementations.
+func ElementBytes(data []byte) [elementByteSize]byte {
+	var element uint3072
+	dataToElement(data, &element)
+	if element.IsOverflow() {
+		element.FullReduce()
+	}
+	var out [elementByteSize]byte
+	wordsToBytesLE(&element, &out)
+	return out
+}
+
+// HashSingle returns the finalized hash of a set containing only the given data.
+// Equivalent to adding it to a new MuHash and finalizing it, without allocating a MuHash.
+func HashSingle(data []byte) Hash {
+	single := MuHash{denominator: one()}
+	dataToElement(data, &single.numerator)
+	return single.Finalize()
+}
+
+// HashSet returns the finalized hash of a set containing all the given elements.
+// Equivalent to adding each of them to a new MuHash and finalizing it, so the result doesn't depend on their order.
+func HashSet(elements [][]byte) Hash {
+	return GenesisMuHash(elements).Finalize()
+}
+
+// GenesisMuHash returns a new set containing all the given elements, e.g. the initial UTXOs of a chain,
+// so that a well known commitment is computed in one place. Equivalent to adding each of them to a new MuHash.
+// Every call returns a new set, which the caller is free to modify.
+func GenesisMuHash(elements [][]byte) *MuHash {
+	set := NewMuHash()
+	for _, element := range elements {
+		set.Add(element)
+	}
+	return set
+}
+
+// ComputeTestVector computes a test vector for other MuHash implementations to validate against.
+// multiset is the finalized hash of a set containing only the last element, and cumulative is
+// the finalized hash of a set containing all the elements. Computing it for each prefix of the elements
+// produces the vectors this package is tested with. If there are no elements both are EmptyMuHashHash.
+func ComputeTestVector(elements [][]byte) (multiset Hash, cumulative Hash) {
+	if len(elements) == 0 {
+		return EmptyMuHashHash, EmptyMuHashHash
+	}
+	return HashSingle(elements[len(elements)-1]), HashSet(elements)
+}
+
+func (mu *MuHash) dataToElement(data []byte, out *uint3072) {
+	if mu.elementHasher != nil {
+		scratch := elementScratchPool.Get().(*elementScratch)
+		if mu.elementHasher.hash != nil {
+			scratch.hashed = mu.elementHasher.hash(data)
+		} else {
+			scratch.hasher.Reset()
+			scratch.hasher.Write(mu.elementHasher.domainPrefix)
+			scratch.hasher.Write(data)
+			scratch.hasher.Sum(scratch.hashed[:0])
+		}
+		scratch.hashToElement(out)
+		elementScratchPool.Put(scratch)
+		return
+	}
+	dataToElement(data, out)
+}
+
+// elementScratch holds the state needed to derive an element, it's pooled so that Add and Remove won't allocate.
+type elementScratch struct {
+	hasher       hash.Hash
+	hashed       Hash
+	elementBytes [elementByteSize]byte
+}
+
+var elementScratchPool = sync.Pool{
+	New: func() interface{} {
+		return &elementScratch{hasher: newElementHasher()}
+	},
+}
+
+func dataToElement(data []byte, out *uint3072) {
+	scratch := elementScratchPool.Get().(*elementScratch)
+	scratch.hasher.Reset()
+	scratch.hasher.Write(data)
+	scratch.hasher.Sum(scratch.hashed[:0])
+	scratch.hashToElement(out)
+	elementScratchPool.Put(scratch)
+}
+
+// elementHash returns the MuHashElement blake2b hash of the data, which is the key its element is derived from.
+func elementHash(data []byte) Hash {
+	scratch := elementScratchPool.Get().(*elementScratch)
+	scratch.hasher.Reset()
+	scratch.hasher.Write(data)
 	var hashed Hash
+	scratch.hasher.Sum(hashed[:0])
+	elementScratchPool.Put(scratch)
+	return hashed
+}
+
+// init constructs the chacha20 cipher that expands hashes into elements once, so that if its key or nonce
+// sizes ever stop matching, the package fails loudly when it's loaded instead of in the middle of an Add.
+func init() {
+	var key Hash
+	var zeroNonce [chacha20.NonceSize]byte
+	_, err := chacha20.NewUnauthenticatedCipher(key[:], zeroNonce[:])
+	if err != nil {
+		panic(errors.Wrapf(err, "MuHash elements can't be derived with chacha20 using a %d byte key", len(key)))
+	}
+}
+
+func newElementHasher() hash.Hash {
 	blake, err := blake2b.New256([]byte("MuHashElement"))
 	if err != nil {
 		panic(errors.Wrap(err, "this should never happen. MuHashElement is less than 64 bytes"))
 	}
-	blake.Write(data)
+	return blake
+}
+
+func hasherToElement(blake hash.Hash, out *uint3072) {
+	var hashed Hash
 	blake.Sum(hashed[:0])
-	stream, err := chacha20.NewUnauthenticatedCipher(hashed[:], zeros12[:])
+	hashToElement(&hashed, out)
+}
+
+// hashToElement expands the hash into a field element by using it as a key for the chacha20 stream.
+func hashToElement(hashed *Hash, out *uint3072) {
+	scratch := elementScratchPool.Get().(*elementScratch)
+	scratch.hashed = *hashed
+	scratch.hashToElement(out)
+	elementScratchPool.Put(scratch)
+}
+
+func (scratch *elementScratch) hashToElement(out *uint3072) {
+	var zeroNonce [chacha20.NonceSize]byte
+	stream, err := chacha20.NewUnauthenticatedCipher(scratch.hashed[:], zeroNonce[:])
 	if err != nil {
-		panic(err)
+		panic(errors.Wrap(err, "this should never happen. The key and nonce sizes are fixed and checked in init"))
+	}
+	// The buffer must be zeroed, because the key stream is XORed into it.
+	// It's pooled because without assembly the XOR makes it escape to the heap.
+	scratch.elementBytes = [elementByteSize]byte{}
+	stream.XORKeyStream(scratch.elementBytes[:], scratch.elementBytes[:])
+	bytesToWordsLE(&scratch.elementBytes, out)
+}
+
+func bytesToWordsLE(elementsBytes *[elementByteSize]byte, elementsWords *uint3072) {
+	for i := range elementsWords {
+		switch wordSize {
+		case 64:
+			elementsWords[i] = uint(binary.LittleEndian.Uint64(elementsBytes[i*wordSizeInBytes:]))
+		case 32:
+			elementsWords[i] = uint(binary.LittleEndian.Uint32(elementsBytes[i*wordSizeInBytes:]))
+		default:
+			panic("Only 32/64 bits machines are supported")
+		}
 	}
-	var elementsBytes [elementByteSize]byte
-	stream.XORKeyStream(elementsBytes[:], elementsBytes[:])
-	bytesToWordsLE(&elementsBytes, &out.limbs)
 }
 
-func bytesToWordsLE(elementsBytes *[elementByteSize]byte, elementsWords *[elementWordSize]word) {
+func wordsToBytesLE(elementsWords *uint3072, elementsBytes *[elementByteSize]byte) {
 	for i := range elementsWords {
 		switch wordSize {
 		case 64:
-			elementsWords[i] = word(binary.LittleEndian.Uint64(elementsBytes[i*wordSizeInBytes:]))
+			binary.LittleEndian.PutUint64(elementsBytes[i*wordSizeInBytes:], uint64(elementsWords[i]))
 		case 32:
-			elementsWords[i] = word(binary.LittleEndian.Uint32(elementsBytes[i*wordSizeInBytes:]))
+			binary.LittleEndian.PutUint32(elementsBytes[i*wordSizeInBytes:], uint32(elementsWords[i]))
 		default:
 			panic("Only 32/64 bits machines are supported")
 		}
@@ -2,12 +2,24 @@ package muhash
 
 import (
 	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/gob"
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"github.com/kaspanet/go-muhash/internal/fieldbackend"
+	"golang.org/x/crypto/blake2b"
+	"golang.org/x/crypto/chacha20"
+	"io"
+	"math/big"
 	"math/rand"
 	"os"
+	"runtime"
 	"strings"
+	"sync"
 	"testing"
 )
 
@@ -71,9 +83,9 @@ func TestMain(m *testing.M) {
 		}
 		testVectors = append(testVectors, res)
 	}
-	var max num3072
-	for i := range max.limbs {
-		max.limbs[i] = ^word(0)
+	var max uint3072
+	for i := range max {
+		max[i] = maxUint
 	}
 	maxMuHash = MuHash{
 		numerator:   max,
@@ -91,6 +103,7 @@ func elementFromByte(i byte) []byte {
 func TestRandomMuHashArithmetic(t *testing.T) {
 	t.Parallel()
 	r := rand.New(rand.NewSource(1))
+	var adds, removes int
 	for i := 0; i < 10; i++ {
 		var res Hash
 		var table [4]byte
@@ -101,10 +114,13 @@ func TestRandomMuHashArithmetic(t *testing.T) {
 			acc := NewMuHash()
 			for i := 0; i < 4; i++ {
 				t := table[i^order]
-				if (t & 4) == 1 {
+				// The third bit picks removing or adding the element in the lower two bits.
+				if t&4 != 0 {
 					acc.Remove(elementFromByte(t & 3))
+					removes++
 				} else {
 					acc.Add(elementFromByte(t & 3))
+					adds++
 				}
 			}
 			out := acc.Finalize()
@@ -133,6 +149,122 @@ func TestRandomMuHashArithmetic(t *testing.T) {
 			t.Fatalf("Expected %s == %s", z.Finalize(), EmptyMuHashHash)
 		}
 	}
+	if adds == 0 || removes == 0 {
+		t.Fatalf("Expected both adding and removing to be exercised, found %d adds and %d removes", adds, removes)
+	}
+}
+
+// TestMuHash_GroupAxioms checks that sets form an abelian group under Combine, with Add and Remove as the
+// group operation on single elements, over random sets.
+func TestMuHash_GroupAxioms(t *testing.T) {
+	t.Parallel()
+	r := rand.New(rand.NewSource(4))
+	randomData := func() []byte {
+		data := make([]byte, 1+r.Intn(100))
+		r.Read(data)
+		return data
+	}
+	randomSet := func() *MuHash {
+		set := NewMuHash()
+		for i := r.Intn(5); i > 0; i-- {
+			if r.Intn(3) == 0 {
+				set.Remove(randomData())
+			} else {
+				set.Add(randomData())
+			}
+		}
+		return set
+	}
+	combine := func(sets ...*MuHash) Hash {
+		result := NewMuHash()
+		for _, set := range sets {
+			result.Combine(set)
+		}
+		return result.Finalize()
+	}
+
+	properties := []struct {
+		name  string
+		holds func() bool
+	}{
+		{"Combine is associative", func() bool {
+			a, b, c := randomSet(), randomSet(), randomSet()
+			left := a.Clone()
+			left.Combine(b)
+			left.Combine(c)
+			bc := b.Clone()
+			bc.Combine(c)
+			right := a.Clone()
+			right.Combine(bc)
+			return left.Finalize() == right.Finalize()
+		}},
+		{"Combine is commutative", func() bool {
+			a, b := randomSet(), randomSet()
+			return combine(a, b) == combine(b, a)
+		}},
+		{"Add is commutative", func() bool {
+			elements := make([][]byte, 1+r.Intn(10))
+			for i := range elements {
+				elements[i] = randomData()
+			}
+			inOrder, shuffled := NewMuHash(), NewMuHash()
+			for _, element := range elements {
+				inOrder.Add(element)
+			}
+			for _, i := range r.Perm(len(elements)) {
+				shuffled.Add(elements[i])
+			}
+			return inOrder.Finalize() == shuffled.Finalize()
+		}},
+		{"Remove is the inverse of Add", func() bool {
+			set, data := randomSet(), randomData()
+			expected := set.Finalize()
+			added := set.Clone()
+			added.Add(data)
+			added.Remove(data)
+			removed := set.Clone()
+			removed.Remove(data)
+			removed.Add(data)
+			return added.Finalize() == expected && removed.Finalize() == expected
+		}},
+		{"Diff is the inverse of Combine", func() bool {
+			a := randomSet()
+			inverse := NewMuHash().Diff(a)
+			inverse.Combine(a)
+			return inverse.IsEmpty() && inverse.Finalize() == EmptyMuHashHash
+		}},
+		{"The empty set is the identity", func() bool {
+			a := randomSet()
+			expected := a.Finalize()
+			withEmpty := a.Clone()
+			withEmpty.Combine(NewMuHash())
+			return withEmpty.Finalize() == expected && combine(NewMuHash(), a) == expected
+		}},
+	}
+	for _, property := range properties {
+		for i := 0; i < 20; i++ {
+			if !property.holds() {
+				t.Fatalf("%s: doesn't hold in iteration %d", property.name, i)
+			}
+		}
+	}
+}
+
+func TestHash_Matches(t *testing.T) {
+	t.Parallel()
+	set := NewMuHash()
+	set.Add(elementFromByte(1))
+	checkpoint := set.Finalize()
+	if !checkpoint.Matches(set) {
+		t.Fatalf("Expected %s to match its set", checkpoint)
+	}
+	if !EmptyMuHashHash.Matches(NewMuHash()) {
+		t.Fatalf("Expected EmptyMuHashHash to match an empty set")
+	}
+	set.Add(elementFromByte(2))
+	if checkpoint.Matches(set) {
+		t.Fatalf("Expected %s not to match a set with another element", checkpoint)
+	}
 }
 
 func TestNewPreComputed(t *testing.T) {
@@ -173,95 +305,2116 @@ func TestMuHash_Serialize(t *testing.T) {
 	if err != nil {
 		t.Fatalf("Failed deserializing muhash: %v", err)
 	}
-	checkHash := check.Finalize()
-	if !deserialized.Finalize().IsEqual(&checkHash) {
-		t.Fatalf("Expected %s == %s", deserialized.Finalize(), check.Finalize())
-	}
+	checkHash := check.Finalize()
+	if !deserialized.Finalize().IsEqual(&checkHash) {
+		t.Fatalf("Expected %s == %s", deserialized.Finalize(), check.Finalize())
+	}
+
+	overflow, err := hex.DecodeString("ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff")
+	if err != nil {
+		t.Fatalf("Failed deserializing hex string: %v", err)
+	}
+
+	if copy(serialized[:], overflow) != len(overflow) {
+		t.Fatalf("Failed copying %x into SerializedMuHash", overflow)
+	}
+
+	_, err = DeserializeMuHash(serialized)
+	if !errors.Is(err, ErrOverflow) {
+		t.Fatalf("Expected %s, instead found: %s", ErrOverflow, err)
+	}
+
+	serializedZeros := SerializedMuHash{}
+	zeroed := NewMuHash()
+	zeroed.addElement(&uint3072{}) // multiply by zero.
+	serialized = zeroed.Serialize()
+	if !bytes.Equal(serialized[:], serializedZeros[:]) {
+		t.Fatalf("expected serialized to be all zeros, instead found: %s", serialized)
+	}
+	_, err = DeserializeMuHash(serialized)
+	if !errors.Is(err, ErrZeroMuHash) {
+		t.Fatalf("Expected %s, instead found: %v", ErrZeroMuHash, err)
+	}
+	deserialized, err = DeserializeMuHashAllowZero(serialized)
+	if err != nil {
+		t.Fatalf("Failed deserializing zeros: %v", err)
+	}
+	zeroed.normalize()
+	deserialized.normalize()
+	if zeroed.numerator != deserialized.numerator {
+		t.Fatalf("Expected %x == %x", zeroed.numerator, deserialized.numerator)
+	}
+}
+
+func TestDeserializeMuHash_Zero(t *testing.T) {
+	t.Parallel()
+	var zeros SerializedMuHash
+	if err := zeros.Validate(); !errors.Is(err, ErrZeroMuHash) {
+		t.Fatalf("Expected %s, instead found: %v", ErrZeroMuHash, err)
+	}
+	if _, err := DeserializeMuHashFromSlice(zeros[:]); !errors.Is(err, ErrZeroMuHash) {
+		t.Fatalf("Expected %s, instead found: %v", ErrZeroMuHash, err)
+	}
+
+	set := NewMuHash()
+	set.Add(elementFromByte(1))
+	setHash := set.Finalize()
+	if err := set.SetFromSerialized(&zeros); !errors.Is(err, ErrZeroMuHash) {
+		t.Fatalf("Expected %s, instead found: %v", ErrZeroMuHash, err)
+	}
+	if err := set.CombineSerialized(&zeros); !errors.Is(err, ErrZeroMuHash) {
+		t.Fatalf("Expected %s, instead found: %v", ErrZeroMuHash, err)
+	}
+	if set.Finalize() != setHash {
+		t.Fatalf("Expected a rejected zero MuHash not to modify the set")
+	}
+
+	zero, err := DeserializeMuHashAllowZero(&zeros)
+	if err != nil {
+		t.Fatalf("DeserializeMuHashAllowZero: %v", err)
+	}
+	if *zero.Serialize() != zeros {
+		t.Fatalf("Expected %s == %s", zero.Serialize(), zeros)
+	}
+	overflow := SerializedMuHash{}
+	for i := range overflow {
+		overflow[i] = 0xff
+	}
+	if _, err := DeserializeMuHashAllowZero(&overflow); !errors.Is(err, ErrOverflow) {
+		t.Fatalf("Expected %s, instead found: %v", ErrOverflow, err)
+	}
+}
+
+func TestDeserializeMuHash_PrimeBoundary(t *testing.T) {
+	t.Parallel()
+	// The bytes are converted to limbs of the machine's word size, so running this test on both
+	// 32 and 64 bit architectures covers both limb layouts.
+	tests := []struct {
+		name     string
+		value    *big.Int
+		overflow bool
+	}{
+		{"one", big.NewInt(1), false},
+		{"prime-2", new(big.Int).Sub(prime, big.NewInt(2)), false},
+		{"prime-1", new(big.Int).Sub(prime, big.NewInt(1)), false},
+		{"prime", prime, true},
+		{"prime+1", new(big.Int).Add(prime, big.NewInt(1)), true},
+		{"2^3072-1", new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), elementBitSize), big.NewInt(1)), true},
+	}
+	for _, test := range tests {
+		var serialized SerializedMuHash
+		copy(serialized[:], bigIntToLEBytes(test.value))
+
+		var limbs uint3072
+		bytesToWordsLE((*[elementByteSize]byte)(&serialized), &limbs)
+		if limbs.IsOverflow() != test.overflow {
+			t.Fatalf("%s: Expected IsOverflow to be %t", test.name, test.overflow)
+		}
+
+		deserialized, err := DeserializeMuHash(&serialized)
+		if test.overflow {
+			if !errors.Is(err, ErrOverflow) {
+				t.Fatalf("%s: Expected %s, instead found: %v", test.name, ErrOverflow, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: Failed deserializing muhash: %v", test.name, err)
+		}
+		if *deserialized.Serialize() != serialized {
+			t.Fatalf("%s: Expected the serialization to round trip, got %s", test.name, deserialized.Serialize())
+		}
+	}
+}
+
+func TestSerializedMuHash_Validate(t *testing.T) {
+	t.Parallel()
+	r := rand.New(rand.NewSource(0))
+	var serialized []*SerializedMuHash
+	for _, value := range []*big.Int{
+		big.NewInt(0),
+		new(big.Int).Sub(prime, big.NewInt(1)),
+		prime,
+		new(big.Int).Add(prime, big.NewInt(1)),
+	} {
+		var boundary SerializedMuHash
+		copy(boundary[:], bigIntToLEBytes(value))
+		serialized = append(serialized, &boundary)
+	}
+	for i := 0; i < 100; i++ {
+		var random SerializedMuHash
+		r.Read(random[:])
+		// Set the top bytes to make overflows likely.
+		if i%2 == 0 {
+			for j := 8; j < len(random); j++ {
+				random[j] = 0xff
+			}
+		}
+		serialized = append(serialized, &random)
+	}
+
+	var valid, invalid int
+	for i, s := range serialized {
+		_, deserializeErr := DeserializeMuHash(s)
+		err := s.Validate()
+		// The errors are wrapped with context, so they're compared by their messages.
+		if fmt.Sprint(err) != fmt.Sprint(deserializeErr) {
+			t.Fatalf("#%d: Expected Validate to return %v like DeserializeMuHash, instead found: %v", i, deserializeErr, err)
+		}
+		if err == nil {
+			valid++
+		} else {
+			invalid++
+		}
+	}
+	if valid == 0 || invalid == 0 {
+		t.Fatalf("Expected both valid and invalid inputs, found %d valid and %d invalid", valid, invalid)
+	}
+}
+
+func TestDeserializeMuHashFromSlice(t *testing.T) {
+	t.Parallel()
+	set := NewMuHash()
+	set.Add(elementFromByte(1))
+	serialized := set.Serialize()
+	deserialized, err := DeserializeMuHashFromSlice(serialized[:])
+	if err != nil {
+		t.Fatalf("Failed deserializing muhash: %v", err)
+	}
+	expected := set.Finalize()
+	if !deserialized.Finalize().IsEqual(&expected) {
+		t.Fatalf("Expected %s == %s", deserialized.Finalize(), expected)
+	}
+
+	for _, length := range []int{0, SerializedMuHashSize - 1, SerializedMuHashSize + 1} {
+		_, err = DeserializeMuHashFromSlice(make([]byte, length))
+		if err == nil {
+			t.Fatalf("DeserializeMuHashFromSlice should fail on a slice of length %d", length)
+		}
+		if !strings.Contains(err.Error(), "invalid") || !strings.Contains(err.Error(), "length") {
+			t.Errorf("Expected the error message to contain the words 'invalid' and 'length', instead found: %s", err)
+		}
+	}
+
+	_, err = DeserializeMuHashFromSlice(bytes.Repeat([]byte{0xff}, SerializedMuHashSize))
+	if !errors.Is(err, ErrOverflow) {
+		t.Fatalf("Expected %s, instead found: %v", ErrOverflow, err)
+	}
+}
+
+func TestMuHash_SerializeBigEndian(t *testing.T) {
+	t.Parallel()
+	set := NewMuHash()
+	set.Add(elementFromByte(1))
+	set.Remove(elementFromByte(2))
+
+	bigEndian := set.SerializeBigEndian()
+	littleEndian := set.Serialize()
+	for i := range bigEndian {
+		if bigEndian[i] != littleEndian[len(littleEndian)-1-i] {
+			t.Fatalf("Expected the big endian serialization to be the reverse of the little endian one, differs at byte %d", i)
+		}
+	}
+
+	deserialized, err := DeserializeMuHashBigEndian(&bigEndian)
+	if err != nil {
+		t.Fatalf("Failed deserializing muhash: %v", err)
+	}
+	if deserialized.Finalize() != set.Finalize() {
+		t.Fatalf("Expected %s == %s", deserialized.Finalize(), set.Finalize())
+	}
+
+	var overflow [SerializedMuHashSize]byte
+	copy(overflow[:], bytes.Repeat([]byte{0xff}, SerializedMuHashSize))
+	_, err = DeserializeMuHashBigEndian(&overflow)
+	if !errors.Is(err, ErrOverflow) {
+		t.Fatalf("Expected %s, instead found: %v", ErrOverflow, err)
+	}
+}
+
+func TestSerializedMuHash_Equal(t *testing.T) {
+	t.Parallel()
+	set := NewMuHash()
+	set.Add(elementFromByte(1))
+	serialized := set.Serialize()
+	// The same set in a different representation serializes identically.
+	other := NewMuHash()
+	other.Add(elementFromByte(1))
+	other.Add(elementFromByte(2))
+	other.Remove(elementFromByte(2))
+	if !serialized.Equal(other.Serialize()) {
+		t.Fatalf("Expected %s == %s", serialized, other.Serialize())
+	}
+	if !serialized.Equal(serialized) {
+		t.Fatalf("Expected a serialized muhash to equal itself")
+	}
+
+	other.Add(elementFromByte(2))
+	if serialized.Equal(other.Serialize()) {
+		t.Fatalf("Expected %s != %s", serialized, other.Serialize())
+	}
+	// Differing only in the last byte.
+	modified := *serialized
+	modified[SerializedMuHashSize-1]++
+	if serialized.Equal(&modified) {
+		t.Fatalf("Expected %s != %s", serialized, &modified)
+	}
+}
+
+func TestSerializedMuHashFromBytes(t *testing.T) {
+	t.Parallel()
+	set := NewMuHash()
+	set.Add(elementFromByte(1))
+	serialized := set.Serialize()
+
+	data := serialized.Bytes()
+	if !bytes.Equal(data, serialized[:]) {
+		t.Fatalf("Expected %x == %x", data, serialized[:])
+	}
+	fromBytes, err := SerializedMuHashFromBytes(data)
+	if err != nil {
+		t.Fatalf("Failed parsing serialized muhash: %v", err)
+	}
+	if *fromBytes != *serialized {
+		t.Fatalf("Expected %s == %s", fromBytes, serialized)
+	}
+	// The result is a copy, so it shouldn't change along with the input.
+	data[0]++
+	if *fromBytes == *serialized {
+		t.Fatalf("Expected SerializedMuHashFromBytes to copy its input")
+	}
+
+	for _, length := range []int{0, SerializedMuHashSize - 1, SerializedMuHashSize + 1} {
+		_, err = SerializedMuHashFromBytes(make([]byte, length))
+		if err == nil {
+			t.Fatalf("SerializedMuHashFromBytes should fail on a slice of length %d", length)
+		}
+		if !strings.Contains(err.Error(), "invalid") || !strings.Contains(err.Error(), "length") {
+			t.Errorf("Expected the error message to contain the words 'invalid' and 'length', instead found: %s", err)
+		}
+	}
+}
+
+func TestMuHash_ZeroElement(t *testing.T) {
+	t.Parallel()
+	var zeroHash Hash
+	blake, err := blake2b.New256([]byte("MuHashFinalize"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	blake.Write(make([]byte, elementByteSize))
+	blake.Sum(zeroHash[:0])
+
+	var primeElement uint3072
+	for i := range primeElement {
+		primeElement[i] = maxUint
+	}
+	primeElement[0] -= primeDiff - 1
+
+	for _, zero := range []uint3072{{}, primeElement} {
+		// Adding a zero element zeroes the set, and nothing brings it back.
+		added := NewMuHash()
+		added.Add(elementFromByte(1))
+		added.addElement(&zero)
+		added.Add(elementFromByte(2))
+		added.Remove(elementFromByte(1))
+		if added.Finalize() != zeroHash {
+			t.Fatalf("Expected %s == %s", added.Finalize(), zeroHash)
+		}
+		if added.IsEmpty() {
+			t.Fatalf("Expected a zeroed set not to be empty")
+		}
+
+		// Removing a zero element zeroes the set as well, since zero has no inverse.
+		removed := NewMuHash()
+		removed.Add(elementFromByte(1))
+		removed.removeElement(&zero)
+		if removed.Finalize() != zeroHash {
+			t.Fatalf("Expected %s == %s", removed.Finalize(), zeroHash)
+		}
+	}
+}
+
+func TestMuHash_SerializeRaw(t *testing.T) {
+	t.Parallel()
+	set := NewMuHash()
+	set.Add(elementFromByte(1))
+	set.Remove(elementFromByte(2))
+	raw := set.SerializeRaw()
+
+	var numerator, denominator [elementByteSize]byte
+	wordsToBytesLE(&set.numerator, &numerator)
+	wordsToBytesLE(&set.denominator, &denominator)
+	if !bytes.Equal(raw[:elementByteSize], numerator[:]) || !bytes.Equal(raw[elementByteSize:], denominator[:]) {
+		t.Fatalf("Expected the raw serialization to be the numerator followed by the denominator, found: %x", raw)
+	}
+
+	deserialized := DeserializeRaw(&raw)
+	if deserialized.numerator != set.numerator || deserialized.denominator != set.denominator {
+		t.Fatalf("Expected the raw deserialization to restore the exact state")
+	}
+	expected := set.Finalize()
+	if !deserialized.Finalize().IsEqual(&expected) {
+		t.Fatalf("Expected %s == %s", deserialized.Finalize(), expected)
+	}
+
+	// Normalizing doesn't change the set, but does change its raw serialization.
+	normalized := set.Clone()
+	normalized.normalize()
+	if normalized.SerializeRaw() == raw {
+		t.Fatalf("Expected the raw serialization of a normalized set to differ")
+	}
+	if *normalized.Serialize() != *set.Serialize() {
+		t.Fatalf("Expected %s == %s", normalized.Serialize(), set.Serialize())
+	}
+}
+
+func TestMuHash_SetFromSerialized(t *testing.T) {
+	set := NewMuHash()
+	set.Add(elementFromByte(1))
+	set.Remove(elementFromByte(2))
+	serialized := set.Serialize()
+	expected := set.Finalize()
+
+	reused := NewMuHash()
+	reused.Add(elementFromByte(3))
+	reused.Finalize()
+	allocs := testing.AllocsPerRun(10, func() {
+		err := reused.SetFromSerialized(serialized)
+		if err != nil {
+			t.Fatalf("Failed deserializing muhash: %v", err)
+		}
+	})
+	if allocs != 0 {
+		t.Fatalf("Expected SetFromSerialized not to allocate, found %f allocations per run", allocs)
+	}
+	if !reused.Finalize().IsEqual(&expected) {
+		t.Fatalf("Expected %s == %s", reused.Finalize(), expected)
+	}
+
+	var overflow SerializedMuHash
+	for i := range overflow {
+		overflow[i] = 0xff
+	}
+	before := *reused
+	err := reused.SetFromSerialized(&overflow)
+	if !errors.Is(err, ErrOverflow) {
+		t.Fatalf("Expected %s, instead found: %v", ErrOverflow, err)
+	}
+	if *reused != before {
+		t.Fatalf("A failed SetFromSerialized shouldn't modify the muhash")
+	}
+}
+
+func TestMuHash_ResetSetFromSerialized(t *testing.T) {
+	serialized := make([]*SerializedMuHash, 10)
+	for i := range serialized {
+		set := NewMuHash()
+		set.Add(elementFromByte(byte(i)))
+		set.Remove(elementFromByte(byte(i + 1)))
+		serialized[i] = set.Serialize()
+	}
+
+	reused := NewMuHash()
+	for i := range serialized {
+		reused.Add(elementFromByte(0xff))
+		reused.Finalize()
+		reused.Reset()
+		err := reused.SetFromSerialized(serialized[i])
+		if err != nil {
+			t.Fatalf("Failed deserializing muhash: %v", err)
+		}
+		fresh, err := DeserializeMuHash(serialized[i])
+		if err != nil {
+			t.Fatalf("Failed deserializing muhash: %v", err)
+		}
+		if *reused != *fresh {
+			t.Fatalf("Set #%d: Expected Reset and SetFromSerialized to equal DeserializeMuHash", i)
+		}
+	}
+
+	allocs := testing.AllocsPerRun(10, func() {
+		for i := range serialized {
+			reused.Reset()
+			_ = reused.SetFromSerialized(serialized[i])
+		}
+	})
+	if allocs != 0 {
+		t.Fatalf("Expected Reset and SetFromSerialized not to allocate, found %f allocations per run", allocs)
+	}
+}
+
+func TestSerializeMany(t *testing.T) {
+	sets := make([]*MuHash, len(testVectors))
+	for i, test := range testVectors {
+		sets[i] = NewMuHash()
+		sets[i].Add(test.dataElement)
+		sets[i].Remove(elementFromByte(byte(i)))
+	}
+	serialized := SerializeMany(sets)
+	if len(serialized) != SerializedSize(len(sets)) {
+		t.Fatalf("Expected %d bytes, found %d", SerializedSize(len(sets)), len(serialized))
+	}
+	for i, set := range sets {
+		if !bytes.Equal(serialized[i*SerializedMuHashSize:(i+1)*SerializedMuHashSize], set.Serialize()[:]) {
+			t.Fatalf("Set #%d: Expected %x == %s", i, serialized[i*SerializedMuHashSize:(i+1)*SerializedMuHashSize],
+				set.Serialize())
+		}
+	}
+	if len(SerializeMany(nil)) != 0 {
+		t.Fatalf("Expected no sets to serialize into no bytes")
+	}
+
+	if !raceEnabled {
+		allocs := testing.AllocsPerRun(10, func() {
+			SerializeMany(sets)
+		})
+		if allocs != 1 {
+			t.Fatalf("Expected SerializeMany to allocate once, allocated %f times", allocs)
+		}
+	}
+}
+
+func TestDeserializeMany(t *testing.T) {
+	t.Parallel()
+	sets := make([]*MuHash, len(testVectors))
+	for i, test := range testVectors {
+		sets[i] = NewMuHash()
+		sets[i].Add(test.dataElement)
+		sets[i].Remove(elementFromByte(byte(i)))
+	}
+	serialized := SerializeMany(sets)
+	deserialized, err := DeserializeMany(serialized)
+	if err != nil {
+		t.Fatalf("DeserializeMany: %v", err)
+	}
+	if len(deserialized) != len(sets) {
+		t.Fatalf("Expected %d sets, found %d", len(sets), len(deserialized))
+	}
+	for i, set := range sets {
+		if deserialized[i].Finalize() != set.Finalize() {
+			t.Fatalf("Set #%d: Expected %s == %s", i, deserialized[i].Finalize(), set.Finalize())
+		}
+	}
+
+	var count int
+	err = ForEachSerialized(serialized, func(mu *MuHash) error {
+		if mu.Finalize() != sets[count].Finalize() {
+			t.Fatalf("Set #%d: Expected %s == %s", count, mu.Finalize(), sets[count].Finalize())
+		}
+		count++
+		return nil
+	})
+	if err != nil || count != len(sets) {
+		t.Fatalf("Expected ForEachSerialized to visit %d sets, visited %d: %v", len(sets), count, err)
+	}
+	errStop := errors.New("stop")
+	count = 0
+	err = ForEachSerialized(serialized, func(mu *MuHash) error {
+		count++
+		return errStop
+	})
+	if !errors.Is(err, errStop) || count != 1 {
+		t.Fatalf("Expected ForEachSerialized to stop on the first error, visited %d sets: %v", count, err)
+	}
+
+	if _, err := DeserializeMany(serialized[1:]); err == nil {
+		t.Fatalf("Expected DeserializeMany to fail on a length that isn't a multiple of %d", SerializedMuHashSize)
+	}
+	for i := 2 * SerializedMuHashSize; i < 3*SerializedMuHashSize; i++ {
+		serialized[i] = 0xff
+	}
+	_, err = DeserializeMany(serialized)
+	if !errors.Is(err, ErrOverflow) || !strings.Contains(err.Error(), "#2") {
+		t.Fatalf("Expected %s in muhash #2, instead found: %v", ErrOverflow, err)
+	}
+	if deserialized, err := DeserializeMany(nil); err != nil || len(deserialized) != 0 {
+		t.Fatalf("Expected no data to deserialize into no sets, found %d: %v", len(deserialized), err)
+	}
+}
+
+func TestSerializedSize(t *testing.T) {
+	t.Parallel()
+	if size := SerializedSize(3); size != 3*SerializedMuHashSize {
+		t.Fatalf("Expected %d, found %d", 3*SerializedMuHashSize, size)
+	}
+	for _, n := range []int{-1, int(^uint(0)>>1) / 2} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Fatalf("Expected SerializedSize(%d) to panic", n)
+				}
+			}()
+			SerializedSize(n)
+		}()
+	}
+}
+
+func TestMuHash_SerializeToArray(t *testing.T) {
+	set := NewMuHash()
+	set.Add(elementFromByte(1))
+	set.Remove(elementFromByte(2))
+	before := *set
+
+	var out SerializedMuHash
+	set.SerializeToArray(&out)
+	if out != *set.Serialize() {
+		t.Fatalf("Expected %s == %s", &out, set.Serialize())
+	}
+	if *set != before {
+		t.Fatalf("Expected SerializeToArray not to modify the muhash")
+	}
+	if !raceEnabled {
+		allocs := testing.AllocsPerRun(10, func() {
+			set.SerializeToArray(&out)
+		})
+		if allocs != 0 {
+			t.Fatalf("Expected SerializeToArray not to allocate, found %f allocations per run", allocs)
+		}
+	}
+}
+
+func TestMuHash_NormalizedLimbs(t *testing.T) {
+	t.Parallel()
+	if limbs := NewMuHash().NormalizedLimbs(); limbs != [48]uint64{1} {
+		t.Fatalf("Expected the empty set to be 1, found %v", limbs)
+	}
+	set := NewMuHash()
+	set.Add(elementFromByte(1))
+	set.Remove(elementFromByte(2))
+	before := *set
+	limbs := set.NormalizedLimbs()
+	if *set != before {
+		t.Fatalf("Expected NormalizedLimbs not to modify the MuHash")
+	}
+	serialized := set.Serialize()
+	for i, limb := range limbs {
+		if expected := binary.LittleEndian.Uint64(serialized[i*8:]); limb != expected {
+			t.Fatalf("Expected limb %d to be %x, found %x", i, expected, limb)
+		}
+	}
+}
+
+func TestMuHash_AppendSerialized(t *testing.T) {
+	set := NewMuHash()
+	set.Add(elementFromByte(1))
+	set.Remove(elementFromByte(2))
+	serialized := set.Serialize()
+
+	if appended := set.AppendSerialized(nil); !bytes.Equal(appended, serialized[:]) {
+		t.Fatalf("Expected %x == %s", appended, serialized)
+	}
+	prefix := []byte{1, 2, 3}
+	appended := set.AppendSerialized(prefix)
+	if !bytes.Equal(appended[:len(prefix)], prefix) || !bytes.Equal(appended[len(prefix):], serialized[:]) {
+		t.Fatalf("Expected %x to be %x followed by %s", appended, prefix, serialized)
+	}
+
+	buf := make([]byte, 0, SerializedMuHashSize)
+	if !raceEnabled {
+		allocs := testing.AllocsPerRun(10, func() {
+			buf = set.AppendSerialized(buf[:0])
+		})
+		if allocs != 0 {
+			t.Fatalf("Expected AppendSerialized not to allocate, found %f allocations per run", allocs)
+		}
+	}
+}
+
+func TestMuHash_ProtoBytes(t *testing.T) {
+	t.Parallel()
+	set := NewMuHash()
+	set.Add(elementFromByte(1))
+	set.Remove(elementFromByte(2))
+	protoBytes := set.ToProtoBytes()
+	if !bytes.Equal(protoBytes, set.Serialize()[:]) {
+		t.Fatalf("Expected %x == %s", protoBytes, set.Serialize())
+	}
+	// Every call returns a fresh copy.
+	protoBytes[0]++
+	if bytes.Equal(protoBytes, set.ToProtoBytes()) {
+		t.Fatalf("Expected ToProtoBytes to return a new slice")
+	}
+	protoBytes[0]--
+
+	parsed, err := MuHashFromProtoBytes(protoBytes)
+	if err != nil {
+		t.Fatalf("Failed parsing muhash: %v", err)
+	}
+	expected := set.Finalize()
+	if !parsed.Finalize().IsEqual(&expected) {
+		t.Fatalf("Expected %s == %s", parsed.Finalize(), expected)
+	}
+	_, err = MuHashFromProtoBytes(protoBytes[1:])
+	if err == nil {
+		t.Fatalf("MuHashFromProtoBytes should fail on a short slice")
+	}
+	_, err = MuHashFromProtoBytes(bytes.Repeat([]byte{0xff}, SerializedMuHashSize))
+	if !errors.Is(err, ErrOverflow) {
+		t.Fatalf("Expected %s, instead found: %v", ErrOverflow, err)
+	}
+}
+
+func TestMuHashFromHex(t *testing.T) {
+	t.Parallel()
+	set := NewMuHash()
+	set.Add(elementFromByte(1))
+	set.Remove(elementFromByte(2))
+	parsed, err := MuHashFromHex(set.String())
+	if err != nil {
+		t.Fatalf("Failed parsing muhash: %v", err)
+	}
+	expected := set.Finalize()
+	if !parsed.Finalize().IsEqual(&expected) {
+		t.Fatalf("Expected %s == %s", parsed.Finalize(), expected)
+	}
+
+	invalid := []string{
+		"",
+		set.String()[2:],
+		set.String() + "00",
+		"zz" + set.String()[2:],
+	}
+	for _, s := range invalid {
+		_, err = MuHashFromHex(s)
+		if err == nil {
+			t.Fatalf("MuHashFromHex should fail on '%s'", s)
+		}
+	}
+	_, err = MuHashFromHex(strings.Repeat("ff", SerializedMuHashSize))
+	if !errors.Is(err, ErrOverflow) {
+		t.Fatalf("Expected %s, instead found: %v", ErrOverflow, err)
+	}
+}
+
+func TestSerializedMuHash_SetHex(t *testing.T) {
+	set := NewMuHash()
+	set.Add(elementFromByte(1))
+	set.Remove(elementFromByte(2))
+	expected := set.Serialize()
+
+	var serialized SerializedMuHash
+	if err := serialized.SetHex(expected.String()); err != nil {
+		t.Fatalf("SetHex: %v", err)
+	}
+	if serialized != *expected {
+		t.Fatalf("Expected %s == %s", serialized, expected)
+	}
+	var upper SerializedMuHash
+	if err := upper.SetHex(strings.ToUpper(expected.String())); err != nil || upper != *expected {
+		t.Fatalf("Expected upper case hex to decode to %s, found %s: %v", expected, upper, err)
+	}
+
+	invalid := []string{
+		"",
+		expected.String()[2:],
+		expected.String() + "00",
+		"zz" + expected.String()[2:],
+		expected.String()[:len(expected.String())-1] + "g",
+	}
+	for _, s := range invalid {
+		if err := serialized.SetHex(s); err == nil {
+			t.Fatalf("SetHex should fail on '%s'", s)
+		}
+		if serialized != *expected {
+			t.Fatalf("Expected a failed SetHex to leave the serialized muhash unchanged")
+		}
+	}
+
+	str := expected.String()
+	allocs := testing.AllocsPerRun(10, func() {
+		_ = serialized.SetHex(str)
+	})
+	if allocs != 0 {
+		t.Fatalf("Expected SetHex not to allocate, found %f allocations per run", allocs)
+	}
+}
+
+func TestMuHashFromBase64(t *testing.T) {
+	t.Parallel()
+	set := NewMuHash()
+	set.Add(elementFromByte(1))
+	set.Remove(elementFromByte(2))
+	encoded := set.Base64()
+	if encoded != base64.StdEncoding.EncodeToString(set.Serialize()[:]) {
+		t.Fatalf("Expected Base64 to encode the serialization, found %s", encoded)
+	}
+	parsed, err := MuHashFromBase64(encoded)
+	if err != nil {
+		t.Fatalf("Failed parsing muhash: %v", err)
+	}
+	expected := set.Finalize()
+	if !parsed.Finalize().IsEqual(&expected) {
+		t.Fatalf("Expected %s == %s", parsed.Finalize(), expected)
+	}
+
+	// 384 bytes are a multiple of 3, so the encoding has no padding, and any padding is invalid.
+	invalid := []string{
+		"",
+		encoded[4:],
+		encoded + "AAAA",
+		encoded + "====",
+		"!!!!" + encoded[4:],
+		encoded[:len(encoded)-2] + "==",
+	}
+	for _, s := range invalid {
+		_, err = MuHashFromBase64(s)
+		if err == nil {
+			t.Fatalf("MuHashFromBase64 should fail on '%s'", s)
+		}
+	}
+	max := bytes.Repeat([]byte{0xff}, SerializedMuHashSize)
+	_, err = MuHashFromBase64(base64.StdEncoding.EncodeToString(max))
+	if !errors.Is(err, ErrOverflow) {
+		t.Fatalf("Expected %s, instead found: %v", ErrOverflow, err)
+	}
+}
+
+func TestMuHash_WriteToReadMuHash(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	sets := make([]*MuHash, len(testVectors))
+	for i, test := range testVectors {
+		sets[i] = NewMuHash()
+		sets[i].Add(test.dataElement)
+		n, err := sets[i].WriteTo(&buf)
+		if err != nil || n != SerializedMuHashSize {
+			t.Fatalf("Test #%d: failed writing muhash. wrote: '%d' bytes. '%v'", i, n, err)
+		}
+		if !bytes.Equal(buf.Bytes()[i*SerializedMuHashSize:], sets[i].Serialize()[:]) {
+			t.Fatalf("Test #%d: Expected %x == %s", i, buf.Bytes()[i*SerializedMuHashSize:], sets[i].Serialize())
+		}
+	}
+	for i := range sets {
+		read, err := ReadMuHash(&buf)
+		if err != nil {
+			t.Fatalf("Test #%d: failed reading muhash: %v", i, err)
+		}
+		expected := sets[i].Finalize()
+		if !read.Finalize().IsEqual(&expected) {
+			t.Fatalf("Test #%d: Expected %s == %s", i, read.Finalize(), expected)
+		}
+	}
+	_, err := ReadMuHash(&buf)
+	if !errors.Is(err, io.EOF) {
+		t.Fatalf("Expected %s, instead found: %v", io.EOF, err)
+	}
+
+	_, err = ReadMuHash(bytes.NewReader(make([]byte, SerializedMuHashSize-1)))
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("Expected %s, instead found: %v", io.ErrUnexpectedEOF, err)
+	}
+
+	_, err = ReadMuHash(bytes.NewReader(bytes.Repeat([]byte{0xff}, SerializedMuHashSize)))
+	if !errors.Is(err, ErrOverflow) {
+		t.Fatalf("Expected %s, instead found: %v", ErrOverflow, err)
+	}
+}
+
+func TestMuHash_Gob(t *testing.T) {
+	t.Parallel()
+	type state struct {
+		Height uint64
+		Set    *MuHash
+	}
+	set := NewMuHash()
+	for _, test := range testVectors {
+		set.Add(test.dataElement)
+	}
+	set.Remove(testVectors[0].dataElement)
+
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(state{Height: 7, Set: set})
+	if err != nil {
+		t.Fatalf("Failed encoding muhash: %v", err)
+	}
+	var decoded state
+	err = gob.NewDecoder(&buf).Decode(&decoded)
+	if err != nil {
+		t.Fatalf("Failed decoding muhash: %v", err)
+	}
+	expected := set.Finalize()
+	if decoded.Height != 7 || !decoded.Set.Finalize().IsEqual(&expected) {
+		t.Fatalf("Expected %s == %s", decoded.Set.Finalize(), expected)
+	}
+
+	encoded, err := set.GobEncode()
+	if err != nil {
+		t.Fatalf("Failed encoding muhash: %v", err)
+	}
+	if !bytes.Equal(encoded, set.Serialize()[:]) {
+		t.Fatalf("Expected %x == %s", encoded, set.Serialize())
+	}
+	previous := NewMuHash()
+	previous.Add(elementFromByte(1))
+	previousHash := previous.Finalize()
+	err = previous.GobDecode(encoded[:len(encoded)-1])
+	if err == nil {
+		t.Fatalf("GobDecode should fail on a truncated buffer")
+	}
+	if !previous.Finalize().IsEqual(&previousHash) {
+		t.Fatalf("A failed GobDecode shouldn't modify the muhash, found: %s", previous.Finalize())
+	}
+	err = previous.GobDecode(bytes.Repeat([]byte{0xff}, SerializedMuHashSize))
+	if !errors.Is(err, ErrOverflow) {
+		t.Fatalf("Expected %s, instead found: %v", ErrOverflow, err)
+	}
+	// Decoding into a set that was already finalized shouldn't keep the old hash.
+	err = previous.GobDecode(encoded)
+	if err != nil {
+		t.Fatalf("Failed decoding muhash: %v", err)
+	}
+	if !previous.Finalize().IsEqual(&expected) {
+		t.Fatalf("Expected %s == %s", previous.Finalize(), expected)
+	}
+}
+
+func TestMuHash_FinalizeDoesNotMutate(t *testing.T) {
+	t.Parallel()
+	m := NewMuHash()
+	m.Add(elementFromByte(1))
+	m.Remove(elementFromByte(2))
+	before := *m
+
+	hash := m.Finalize()
+	serialized := m.Serialize()
+	if *m != before {
+		t.Fatalf("Finalize and Serialize shouldn't modify the MuHash")
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < runtime.NumCPU(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if !m.Finalize().IsEqual(&hash) {
+				t.Errorf("Expected %s == %s", m.Finalize(), hash)
+			}
+			if *m.Serialize() != *serialized {
+				t.Errorf("Expected %s == %s", m.Serialize(), serialized)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestMuHash_ConcurrentReads(t *testing.T) {
+	t.Parallel()
+	m := NewMuHash()
+	m.Add(elementFromByte(1))
+	m.Remove(elementFromByte(2))
+	before := *m
+	hash := m.Finalize()
+	str := m.String()
+
+	// String and Clone have value receivers, so they copy the whole MuHash while Finalize runs on it.
+	// Run with -race to make sure none of them writes to the set.
+	var wg sync.WaitGroup
+	for i := 0; i < runtime.NumCPU(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if m.String() != str {
+				t.Errorf("Expected %s == %s", m.String(), str)
+			}
+			if clone := m.Clone(); *clone != before {
+				t.Errorf("Expected the clone %s to equal %s", clone, str)
+			}
+			if m.Finalize() != hash {
+				t.Errorf("Expected %s == %s", m.Finalize(), hash)
+			}
+		}()
+	}
+	wg.Wait()
+	// Finalizing a set must not make it unequal to an identical set that wasn't finalized.
+	if *m != before {
+		t.Fatalf("Expected a finalized MuHash to equal its unfinalized copy")
+	}
+}
+
+func TestMuHash_normalizeDoesNotAllocate(t *testing.T) {
+	r := rand.New(rand.NewSource(0))
+	var set MuHash
+	for i := range set.numerator {
+		set.numerator[i] = uint(r.Uint64())
+		set.denominator[i] = uint(r.Uint64())
+	}
+	if !raceEnabled {
+		allocs := testing.AllocsPerRun(10, func() {
+			clone := set
+			clone.normalize()
+		})
+		if allocs != 0 {
+			t.Fatalf("Expected normalize not to allocate, found %f allocations per run", allocs)
+		}
+	}
+}
+
+func TestMuHash_AddDoesNotAllocate(t *testing.T) {
+	set := NewMuHash()
+	data := elementFromByte(1)
+	if !raceEnabled {
+		allocs := testing.AllocsPerRun(100, func() {
+			set.Add(data)
+			set.Remove(data)
+		})
+		if allocs != 0 {
+			t.Fatalf("Expected Add and Remove not to allocate, found %f allocations per run", allocs)
+		}
+	}
+	if !set.IsEmpty() {
+		t.Fatalf("Expected the set to be empty after adding and removing the same element")
+	}
+
+	// The pooled element state is shared between goroutines, so make sure concurrent sets still agree.
+	expected := NewMuHash()
+	for i := 0; i < 100; i++ {
+		expected.Add(elementFromByte(byte(i)))
+	}
+	expectedHash := expected.Finalize()
+	var wg sync.WaitGroup
+	for i := 0; i < runtime.NumCPU()*2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			set := NewMuHash()
+			for i := 0; i < 100; i++ {
+				set.Add(elementFromByte(byte(i)))
+			}
+			if !set.Finalize().IsEqual(&expectedHash) {
+				t.Errorf("Expected %s == %s", set.Finalize(), expectedHash)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestMuHash_FinalizeWithCount(t *testing.T) {
+	t.Parallel()
+	set := NewMuHash()
+	set.Add(elementFromByte(1))
+	set.Add(elementFromByte(2))
+	finalized := set.Finalize()
+
+	// Pin the hash, so it won't change between versions.
+	expected, err := HashFromString("56c45833f388090dc1af0dbc110d255ae7777fe006468ec0f09c2913efe3a26a")
+	if err != nil {
+		t.Fatalf("Failed parsing hash: %v", err)
+	}
+	if set.FinalizeWithCount(2) != expected {
+		t.Fatalf("Expected %s == %s", set.FinalizeWithCount(2), expected)
+	}
+	if set.FinalizeWithCount(3) == expected || set.FinalizeWithCount(2) == finalized {
+		t.Fatalf("Expected the count to change the hash")
+	}
+	if set.Finalize() != finalized {
+		t.Fatalf("Expected FinalizeWithCount not to change Finalize")
+	}
+	if hash := NewMuHash().FinalizeWithCount(0); hash.IsEqual(&EmptyMuHashHash) {
+		t.Fatalf("Expected the empty set with a count to differ from EmptyMuHashHash")
+	}
+}
+
+func TestMuHash_FinalizeIntoAndSum(t *testing.T) {
+	t.Parallel()
+	set := NewMuHash()
+	set.Add(elementFromByte(1))
+	expected := set.Finalize()
+
+	var dst [32]byte
+	set.FinalizeInto(&dst)
+	if Hash(dst) != expected {
+		t.Fatalf("Expected %x == %s", dst, expected)
+	}
+
+	if sum := set.Sum(nil); !bytes.Equal(sum, expected[:]) {
+		t.Fatalf("Expected %x == %s", sum, expected)
+	}
+	prefix := []byte{1, 2, 3}
+	sum := set.Sum(prefix)
+	if !bytes.Equal(sum[:len(prefix)], prefix) || !bytes.Equal(sum[len(prefix):], expected[:]) {
+		t.Fatalf("Expected %x to be %x followed by %s", sum, prefix, expected)
+	}
+}
+
+func TestMuHash_Verify(t *testing.T) {
+	t.Parallel()
+	set := NewMuHash()
+	if !set.Verify(EmptyMuHashHash) {
+		t.Fatalf("Expected an empty set to verify against %s", EmptyMuHashHash)
+	}
+	for _, test := range testVectors {
+		set.Add(test.dataElement)
+	}
+	expected := testVectors[len(testVectors)-1].cumulativeHash
+	before := *set
+
+	var wg sync.WaitGroup
+	for i := 0; i < runtime.NumCPU(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if !set.Verify(expected) {
+				t.Errorf("Expected %s to verify against %s", set.Finalize(), expected)
+			}
+			if set.Verify(EmptyMuHashHash) {
+				t.Errorf("Expected %s not to verify against %s", set.Finalize(), EmptyMuHashHash)
+			}
+		}()
+	}
+	wg.Wait()
+	if *set != before {
+		t.Fatalf("Verify shouldn't modify the MuHash")
+	}
+
+	set.Remove(testVectors[0].dataElement)
+	if set.Verify(expected) {
+		t.Fatalf("Expected a changed set not to verify against its old hash")
+	}
+}
+
+func TestNormalizeBatch(t *testing.T) {
+	t.Parallel()
+	r := rand.New(rand.NewSource(1))
+	sets := make([]*MuHash, 10)
+	expected := make([]Hash, len(sets))
+	for i := range sets {
+		sets[i] = NewMuHash()
+		for j := 0; j < i; j++ {
+			data := [100]byte{}
+			n, err := r.Read(data[:])
+			if err != nil || n != len(data) {
+				t.Fatalf("Failed generating random data. read: '%d' bytes. .'%v'", n, err)
+			}
+			if j%2 == 0 {
+				sets[i].Add(data[:])
+			} else {
+				sets[i].Remove(data[:])
+			}
+		}
+		expected[i] = sets[i].Finalize()
+	}
+	NormalizeBatch(sets)
+	for i, set := range sets {
+		if set.denominator != one() {
+			t.Fatalf("Set #%d: Expected the denominator to be one, found: %v", i, set.denominator)
+		}
+		if !set.Finalize().IsEqual(&expected[i]) {
+			t.Fatalf("Set #%d: Expected %s == %s", i, set.Finalize(), expected[i])
+		}
+	}
+}
+
+func TestNormalizeAll(t *testing.T) {
+	t.Parallel()
+	r := rand.New(rand.NewSource(2))
+	sets := make([]MuHash, 100)
+	for i := range sets {
+		for j := range sets[i].numerator {
+			sets[i].numerator[j] = uint(r.Uint64())
+			sets[i].denominator[j] = uint(r.Uint64())
+		}
+	}
+	sets[0].Reset()
+	expected := make([]MuHash, len(sets))
+	for i := range sets {
+		expected[i] = sets[i]
+		expected[i].normalize()
+	}
+	for _, workers := range []int{0, 1, 4, len(sets)} {
+		normalized := make([]*MuHash, len(sets))
+		for i := range sets {
+			normalized[i] = sets[i].Clone()
+		}
+		NormalizeAll(normalized, workers)
+		for i := range normalized {
+			if normalized[i].numerator != expected[i].numerator || normalized[i].denominator != expected[i].denominator {
+				t.Fatalf("Workers %d, set #%d: Expected NormalizeAll to match normalizing serially", workers, i)
+			}
+		}
+	}
+	NormalizeAll(nil, 4)
+}
+
+// TestMuHash_WordSizeIndependent checks the serialization against constants, so running it with GOARCH=386
+// checks that a MuHash serialized on a 64 bit machine is identical to one serialized on a 32 bit machine.
+func TestMuHash_WordSizeIndependent(t *testing.T) {
+	t.Parallel()
+	expectedHash := "bc9bf3beb01fac28b896cbcd7ffd789980a23087edb6debb0ad1a38e1905fdaa"
+	expectedSerialized, err := hex.DecodeString("91385f31d3d37b49368c80b0eb7efebaf54b73b6226435e52759b5ed264b4065d95c92221f2fbf674a02505c39cbc7a67f8dd444858f95310485a841213296385c68ab36963d4ec733672db7c2a50079a512c17c3a505ab140c8dd9e53cc55b1891eaaac75ad997f5091556f8f4717a53776c95039c823aaa8055e4361a78af4cd8b200d3dcab7f77d821c33251637ca8b2adaa673c18a9a11f7fbb2385b6689190d222fd68d04187a146322edba4b1b94609ce203d6e5bf2a261cdaf72f50bd6efd9a7cee48d1bb0277e23ea274eff5902e3c8265beb0eb792e595f5257014682a965252a320793c31ff4b2d8f5d8995ed392dbcf2fdded419bce638130c0360997de51d4fd28da44ee1cba6986c7f187ce5a1edb2f16f497fa0d07345b29e9308c48c5f90275a21ba2251ee2f89fd619aa0c4cdcbb28a412bd7d07ba2d19a4c13a7b23e2746426319fd6848d354641dfdb42cd86cbb202bdae9dd4de39a06764dcdfe753d15330e15c0a5ea3f4a79179d408b07269fd8a72d688596d1242e0")
+	if err != nil {
+		t.Fatalf("Failed deserializing hex string: %v", err)
+	}
+	set := NewMuHash()
+	set.Add(elementFromByte(1))
+	set.Add(elementFromByte(2))
+	set.Remove(elementFromByte(3))
+	if set.Finalize().String() != expectedHash {
+		t.Fatalf("Expected %s == %s", set.Finalize(), expectedHash)
+	}
+	serialized := set.Serialize()
+	if !bytes.Equal(serialized[:], expectedSerialized) {
+		t.Fatalf("Expected %s == %x", serialized, expectedSerialized)
+	}
+
+	var fixed SerializedMuHash
+	copy(fixed[:], expectedSerialized)
+	deserialized, err := DeserializeMuHash(&fixed)
+	if err != nil {
+		t.Fatalf("Failed deserializing muhash: %v", err)
+	}
+	if deserialized.Finalize().String() != expectedHash {
+		t.Fatalf("Expected %s == %s", deserialized.Finalize(), expectedHash)
+	}
+}
+
+func TestVectorsMuHash_Hash(t *testing.T) {
+	t.Parallel()
+	for _, test := range testVectors {
+		m := NewMuHash()
+		m.Add(test.dataElement)
+		mFinal := m.Finalize()
+		if !m.Finalize().IsEqual(&test.multisetHash) {
+			t.Errorf("MuHash-Hash returned incorrect hash serialization, expected: '%s', found: '%s'", test.multisetHash, mFinal)
+		}
+	}
+	m := NewMuHash()
+	if !m.Finalize().IsEqual(&EmptyMuHashHash) {
+		t.Fatalf("Empty set did not return zero hash, got: '%s' instead", m.Finalize())
+	}
+}
+
+func TestMuHash_CloneInto(t *testing.T) {
+	original := NewMuHash()
+	original.Add(elementFromByte(1))
+	original.Remove(elementFromByte(2))
+	originalHash := original.Finalize()
+	before := *original
+
+	dst := NewMuHash()
+	dst.Add(elementFromByte(3))
+	allocs := testing.AllocsPerRun(10, func() {
+		original.CloneInto(dst)
+	})
+	if allocs != 0 {
+		t.Fatalf("Expected CloneInto not to allocate, found %f allocations per run", allocs)
+	}
+	if dst.Finalize() != originalHash {
+		t.Fatalf("Expected %s == %s", dst.Finalize(), originalHash)
+	}
+
+	clone := original.Clone()
+	// Clone has a value receiver, so it can be called on a non-addressable MuHash.
+	valueClone := func() MuHash { return *original }().Clone()
+	for _, copied := range []*MuHash{dst, clone, valueClone} {
+		copied.Add(elementFromByte(4))
+		copied.Remove(elementFromByte(5))
+		copied.Combine(copied.Clone())
+		if *original != before {
+			t.Fatalf("Changing a copy changed the original")
+		}
+		if original.Finalize() != originalHash {
+			t.Fatalf("Changing a copy changed the original's hash: %s != %s", original.Finalize(), originalHash)
+		}
+		if copied.Finalize() == originalHash {
+			t.Fatalf("Expected the copy to change")
+		}
+	}
+	original.Reset()
+	if dst.IsEmpty() || clone.IsEmpty() {
+		t.Fatalf("Resetting the original changed a copy")
+	}
+}
+
+func TestMuHash_AddFromChannel(t *testing.T) {
+	t.Parallel()
+	ch := make(chan []byte)
+	var wg sync.WaitGroup
+	for producer := 0; producer < 4; producer++ {
+		wg.Add(1)
+		go func(producer int) {
+			defer wg.Done()
+			for i := 0; i < 25; i++ {
+				ch <- elementFromByte(byte(producer*25 + i))
+			}
+		}(producer)
+	}
+	go func() {
+		wg.Wait()
+		close(ch)
+	}()
+
+	set := NewMuHash()
+	if err := set.AddFromChannel(context.Background(), ch); err != nil {
+		t.Fatalf("AddFromChannel: %v", err)
+	}
+	expected := NewMuHash()
+	for i := 0; i < 100; i++ {
+		expected.Add(elementFromByte(byte(i)))
+	}
+	if set.Finalize() != expected.Finalize() {
+		t.Fatalf("Expected %s == %s", set.Finalize(), expected.Finalize())
+	}
+
+	// Canceling stops it even though the channel is never closed.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := NewMuHash().AddFromChannel(ctx, make(chan []byte)); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected %s, instead found: %v", context.Canceled, err)
+	}
+}
+
+func TestMuHash_AddKeyValue(t *testing.T) {
+	t.Parallel()
+	// Pin the framing, so it won't change between versions.
+	m := NewMuHash()
+	m.AddKeyValue([]byte{0xaa}, []byte{0xbb, 0xcc})
+	expected := NewMuHash()
+	expected.Add([]byte{1, 0, 0, 0, 0, 0, 0, 0, 0xaa, 2, 0, 0, 0, 0, 0, 0, 0, 0xbb, 0xcc})
+	if m.Finalize() != expected.Finalize() {
+		t.Fatalf("Expected %s == %s", m.Finalize(), expected.Finalize())
+	}
+	m.RemoveKeyValue([]byte{0xaa}, []byte{0xbb, 0xcc})
+	if !m.IsEmpty() {
+		t.Fatalf("Expected RemoveKeyValue to remove the pair added by AddKeyValue")
+	}
+
+	// Moving bytes between the key and the value, or concatenating them, results in different elements.
+	pairs := [][2][]byte{
+		{[]byte("ab"), []byte("c")},
+		{[]byte("a"), []byte("bc")},
+		{[]byte("abc"), nil},
+		{nil, []byte("abc")},
+		{nil, nil},
+	}
+	hashes := make(map[Hash]int)
+	for i, pair := range pairs {
+		set := NewMuHash()
+		set.AddKeyValue(pair[0], pair[1])
+		if j, ok := hashes[set.Finalize()]; ok {
+			t.Fatalf("Pairs #%d and #%d resulted in the same element", j, i)
+		}
+		hashes[set.Finalize()] = i
+	}
+	concatenated := NewMuHash()
+	concatenated.Add([]byte("abc"))
+	if _, ok := hashes[concatenated.Finalize()]; ok {
+		t.Fatalf("Expected a pair to result in a different element than the concatenation of its key and value")
+	}
+}
+
+func TestMuHash_AddUint64(t *testing.T) {
+	t.Parallel()
+	for _, x := range []uint64{0, 1, 0x0102030405060708, ^uint64(0)} {
+		fromInt := NewMuHash()
+		fromInt.AddUint64(x)
+		var data [8]byte
+		for i := range data {
+			data[i] = byte(x >> (8 * i))
+		}
+		fromBytes := NewMuHash()
+		fromBytes.Add(data[:])
+		if fromInt.Finalize() != fromBytes.Finalize() {
+			t.Fatalf("Expected AddUint64(%d) to equal Add(%x): %s != %s", x, data, fromInt.Finalize(), fromBytes.Finalize())
+		}
+		fromInt.RemoveUint64(x)
+		if !fromInt.IsEmpty() {
+			t.Fatalf("Expected RemoveUint64(%d) to remove AddUint64(%d)", x, x)
+		}
+	}
+	// Pin the encoding of a single element, so it won't change between versions.
+	m := NewMuHash()
+	m.AddUint64(1)
+	expected := NewMuHash()
+	expected.Add([]byte{1, 0, 0, 0, 0, 0, 0, 0})
+	if m.Finalize() != expected.Finalize() {
+		t.Fatalf("Expected %s == %s", m.Finalize(), expected.Finalize())
+	}
+}
+
+func TestMuHash_AddHashed(t *testing.T) {
+	t.Parallel()
+	var digest [32]byte
+	for i := range digest {
+		digest[i] = byte(i)
+	}
+	m := NewMuHash()
+	m.AddHashed(digest)
+	// Pin the element, so it won't change between versions.
+	expected, err := HashFromString("301e6e1f13287ca2fd9d07bbc40e018217d5575a0a0f83d0277c2bf4953f0dd3")
+	if err != nil {
+		t.Fatalf("Failed parsing hash: %v", err)
+	}
+	if m.Finalize() != expected {
+		t.Fatalf("Expected %s == %s", m.Finalize(), expected)
+	}
+
+	rehashed := NewMuHash()
+	rehashed.Add(digest[:])
+	if m.Finalize() == rehashed.Finalize() {
+		t.Fatalf("Expected AddHashed(digest) to differ from Add(digest[:])")
+	}
+
+	// The element hasher of the muhash isn't used.
+	custom := NewMuHashWithHasher(sha256.Sum256)
+	custom.AddHashed(digest)
+	if custom.Finalize() != m.Finalize() {
+		t.Fatalf("Expected AddHashed to ignore the element hasher: %s != %s", custom.Finalize(), m.Finalize())
+	}
+
+	m.RemoveHashed(digest)
+	if !m.IsEmpty() {
+		t.Fatalf("Expected RemoveHashed to remove AddHashed")
+	}
+}
+
+func TestMuHash_AddEmpty(t *testing.T) {
+	t.Parallel()
+	// The finalized hash of a set containing only the empty element.
+	expected, err := HashFromString("e3b7c25ecf8d3d2e368daf106a249c2e4da3eebff6622ccc99a4f901a3c26c6e")
+	if err != nil {
+		t.Fatalf("Failed parsing hash: %v", err)
+	}
+	for _, data := range [][]byte{nil, {}} {
+		m := NewMuHash()
+		m.Add(data)
+		if m.Finalize() != expected {
+			t.Fatalf("Expected %s == %s", m.Finalize(), expected)
+		}
+		m.Remove(data)
+		if !m.IsEmpty() {
+			t.Fatalf("Expected removing the empty element to result in an empty set, found %s", m.Finalize())
+		}
+	}
+}
+
+func TestElementCipherSizes(t *testing.T) {
+	t.Parallel()
+	// The element hash is used directly as the chacha20 key, init relies on this to never fail.
+	if len(Hash{}) != chacha20.KeySize {
+		t.Fatalf("Expected the hash size %d to be the chacha20 key size %d", len(Hash{}), chacha20.KeySize)
+	}
+}
+
+func TestElementBytes(t *testing.T) {
+	t.Parallel()
+	for i, test := range testVectors {
+		element := ElementBytes(test.dataElement)
+		// A set containing only the element serializes to the element itself.
+		m := NewMuHash()
+		m.Add(test.dataElement)
+		if element != *m.Serialize() {
+			t.Fatalf("Test #%d: Expected %x == %s", i, element, m.Serialize())
+		}
+	}
+	// The element of the empty data, as documented on Add.
+	expected := []byte{0x58, 0xc4, 0x08, 0x4f, 0x15, 0x81, 0x28, 0x95, 0xfe, 0x17, 0xed, 0xbc, 0xb0, 0xa1, 0x17, 0x30}
+	element := ElementBytes(nil)
+	if !bytes.Equal(element[:len(expected)], expected) {
+		t.Fatalf("Expected the element of empty data to start with %x, found: %x", expected, element[:len(expected)])
+	}
+}
+
+func TestHashSingle(t *testing.T) {
+	t.Parallel()
+	for i, test := range testVectors {
+		if single := HashSingle(test.dataElement); !single.IsEqual(&test.multisetHash) {
+			t.Fatalf("Test #%d: Expected %s == %s", i, single, test.multisetHash)
+		}
+	}
+	set := NewMuHash()
+	set.Add(nil)
+	if single := HashSingle(nil); single != set.Finalize() {
+		t.Fatalf("Expected %s == %s", single, set.Finalize())
+	}
+}
+
+func TestHashSet(t *testing.T) {
+	t.Parallel()
+	if hash := HashSet(nil); !hash.IsEqual(&EmptyMuHashHash) {
+		t.Fatalf("Expected %s == %s", hash, EmptyMuHashHash)
+	}
+	elements := make([][]byte, 0, len(testVectors))
+	for i, test := range testVectors {
+		elements = append(elements, test.dataElement)
+		if hash := HashSet(elements); !hash.IsEqual(&test.cumulativeHash) {
+			t.Fatalf("Test #%d: Expected %s == %s", i, hash, test.cumulativeHash)
+		}
+	}
+
+	// The hash doesn't depend on the order of the elements.
+	r := rand.New(rand.NewSource(0))
+	expected := HashSet(elements)
+	for i := 0; i < 10; i++ {
+		r.Shuffle(len(elements), func(i, j int) {
+			elements[i], elements[j] = elements[j], elements[i]
+		})
+		if hash := HashSet(elements); hash != expected {
+			t.Fatalf("Shuffle #%d: Expected %s == %s", i, hash, expected)
+		}
+	}
+}
+
+func TestGenesisMuHash(t *testing.T) {
+	t.Parallel()
+	elements := make([][]byte, 0, len(testVectors))
+	for _, test := range testVectors {
+		elements = append(elements, test.dataElement)
+	}
+	genesis := GenesisMuHash(elements)
+	expected := testVectors[len(testVectors)-1].cumulativeHash
+	if !genesis.Finalize().IsEqual(&expected) {
+		t.Fatalf("Expected %s == %s", genesis.Finalize(), expected)
+	}
+	// Modifying one genesis set doesn't affect the next one.
+	genesis.Add(elementFromByte(1))
+	if hash := GenesisMuHash(elements).Finalize(); !hash.IsEqual(&expected) {
+		t.Fatalf("Expected %s == %s", hash, expected)
+	}
+	if !GenesisMuHash(nil).IsEmpty() {
+		t.Fatalf("Expected a genesis without elements to be empty")
+	}
+}
+
+func TestComputeTestVector(t *testing.T) {
+	t.Parallel()
+	elements := make([][]byte, 0, len(testVectors))
+	for i, test := range testVectors {
+		elements = append(elements, test.dataElement)
+		multiset, cumulative := ComputeTestVector(elements)
+		if multiset != test.multisetHash {
+			t.Errorf("Test #%d: Expected multiset hash '%s' but got '%s'", i, test.multisetHash, multiset)
+		}
+		if cumulative != test.cumulativeHash {
+			t.Errorf("Test #%d: Expected cumulative hash '%s' but got '%s'", i, test.cumulativeHash, cumulative)
+		}
+	}
+	multiset, cumulative := ComputeTestVector(nil)
+	if multiset != EmptyMuHashHash || cumulative != EmptyMuHashHash {
+		t.Fatalf("Expected no elements to result in the empty hash, found: '%s', '%s'", multiset, cumulative)
+	}
+}
+
+func TestVectorsMuHash_AddRemove(t *testing.T) {
+	t.Parallel()
+	m := NewMuHash()
+	for i, test := range testVectors {
+		m.Add(test.dataElement)
+		mFinal := m.Finalize()
+		if !mFinal.IsEqual(&test.cumulativeHash) {
+			t.Errorf("Test #%d: MuHash-Add returned incorrect hash. Expected '%s' but got '%s'", i, test.cumulativeHash, mFinal)
+		}
+	}
+
+	for i := len(testVectors) - 1; i > 0; i-- {
+		m.Remove(testVectors[i].dataElement)
+		mFinal := m.Finalize()
+		if !mFinal.IsEqual(&testVectors[i-1].cumulativeHash) {
+			t.Errorf("Test #%d: MuHash-Remove returned incorrect hash. Expected '%s' but got '%s'", i, testVectors[i].cumulativeHash, mFinal)
+		}
+	}
+}
+
+func TestVectorsMuHash_CombineSubtract(t *testing.T) {
+	t.Parallel()
+	m1 := NewMuHash()
+	zeroHash := m1.Finalize()
+
+	for _, test := range testVectors {
+		m1.Add(test.dataElement)
+	}
+
+	m2 := NewMuHash()
+	for _, test := range testVectors {
+		m2.Remove(test.dataElement)
+	}
+	m1.Combine(m2)
+	if !m1.Finalize().IsEqual(&zeroHash) {
+		t.Fatalf("m1 was expected to have a zero hash, but was '%s' instead", m1.Finalize())
+	}
+}
+
+func TestMuHash_CombineAll(t *testing.T) {
+	t.Parallel()
+	r := rand.New(rand.NewSource(1))
+	const shardsN = 8
+	full := NewMuHash()
+	shards := make([]*MuHash, shardsN)
+	for i := range shards {
+		shards[i] = NewMuHash()
+	}
+	for i := 0; i < 100; i++ {
+		data := [100]byte{}
+		n, err := r.Read(data[:])
+		if err != nil || n != len(data) {
+			t.Fatalf("Failed generating random data. read: '%d' bytes. .'%v'", n, err)
+		}
+		shard := shards[r.Intn(shardsN)]
+		if i%3 == 0 {
+			full.Remove(data[:])
+			shard.Remove(data[:])
+		} else {
+			full.Add(data[:])
+			shard.Add(data[:])
+		}
+	}
+	fullHash := full.Finalize()
+
+	combined := NewMuHash()
+	combined.CombineAll(shards...)
+	if !combined.Finalize().IsEqual(&fullHash) {
+		t.Fatalf("Expected %s == %s", combined.Finalize(), fullHash)
+	}
+
+	sequential := NewMuHash()
+	for _, shard := range shards {
+		sequential.Combine(shard)
+	}
+	if !sequential.Finalize().IsEqual(&fullHash) {
+		t.Fatalf("Expected %s == %s", sequential.Finalize(), fullHash)
+	}
+
+	// Combining the shards in a different grouping should result in the same set.
+	grouped := shards[0].Clone()
+	grouped.CombineAll(shards[1 : shardsN/2]...)
+	rest := shards[shardsN/2].Clone()
+	rest.CombineAll(shards[shardsN/2+1:]...)
+	grouped.CombineAll(rest)
+	if !grouped.Finalize().IsEqual(&fullHash) {
+		t.Fatalf("Expected %s == %s", grouped.Finalize(), fullHash)
+	}
+
+	before := *combined
+	combined.CombineAll()
+	if *combined != before {
+		t.Fatalf("CombineAll without arguments should be a no-op")
+	}
+}
+
+func TestMuHash_DenominatorIsOne(t *testing.T) {
+	t.Parallel()
+	set := NewMuHash()
+	if !set.DenominatorIsOne() || set.NeedsNormalize() {
+		t.Fatalf("Expected a new set to have a denominator of one")
+	}
+	set.Add(elementFromByte(1))
+	if !set.DenominatorIsOne() || set.NeedsNormalize() {
+		t.Fatalf("Expected adding not to change the denominator")
+	}
+	set.Remove(elementFromByte(1))
+	if set.DenominatorIsOne() || !set.NeedsNormalize() {
+		t.Fatalf("Expected removing to change the denominator")
+	}
+	// Serializing and finalizing normalize a copy, not the set itself.
+	set.Finalize()
+	if !set.NeedsNormalize() {
+		t.Fatalf("Expected Finalize not to normalize the set")
+	}
+	set.normalize()
+	if !set.DenominatorIsOne() || set.NeedsNormalize() {
+		t.Fatalf("Expected a normalized set to have a denominator of one")
+	}
+	deserialized, err := DeserializeMuHash(set.Serialize())
+	if err != nil {
+		t.Fatalf("Failed deserializing muhash: %v", err)
+	}
+	if !deserialized.DenominatorIsOne() {
+		t.Fatalf("Expected a deserialized set to have a denominator of one")
+	}
+
+	// One plus the prime is one as well.
+	var overflownOne uint3072
+	for i := range overflownOne {
+		overflownOne[i] = maxUint
+	}
+	overflownOne[0] -= primeDiff - 2
+	overflown := MuHash{numerator: overflownOne, denominator: overflownOne}
+	if !overflown.DenominatorIsOne() {
+		t.Fatalf("Expected an overflown one to be one")
+	}
+	overflown.normalize()
+	if overflown.numerator != one() || overflown.denominator != one() {
+		t.Fatalf("Expected normalize to reduce the numerator when the denominator is one, found %v/%v",
+			overflown.numerator, overflown.denominator)
+	}
+}
+
+func TestMuHash_Normalize(t *testing.T) {
+	t.Parallel()
+	set := NewMuHash()
+	set.Add(elementFromByte(1))
+	set.Remove(elementFromByte(2))
+	expected := set.Clone().Finalize()
+	expectedSerialized := set.Serialize()
+
+	set.Normalize()
+	if set.NeedsNormalize() {
+		t.Fatalf("Expected Normalize to leave a denominator of one")
+	}
+	if set.Finalize() != expected {
+		t.Fatalf("Expected %s == %s", set.Finalize(), expected)
+	}
+	numerator := set.numerator
+	set.Normalize()
+	if set.numerator != numerator || set.NeedsNormalize() {
+		t.Fatalf("Expected normalizing twice to have no effect")
+	}
+	if *set.Serialize() != *expectedSerialized {
+		t.Fatalf("Expected %s == %s", set.Serialize(), expectedSerialized)
+	}
+	// The normalized numerator is the serialized form.
+	var numeratorBytes [elementByteSize]byte
+	wordsToBytesLE(&set.numerator, &numeratorBytes)
+	if SerializedMuHash(numeratorBytes) != *expectedSerialized {
+		t.Fatalf("Expected the numerator of a normalized set to be its serialization")
+	}
+}
+
+func TestFieldBackends(t *testing.T) {
+	defer fieldbackend.Set(fieldbackend.Default)
+	backends := []string{fieldbackend.Generic}
+	if fieldbackend.Default != fieldbackend.Generic {
+		backends = append(backends, fieldbackend.Default)
+	}
+	for _, backend := range backends {
+		if !fieldbackend.Set(backend) {
+			t.Fatalf("Expected the %s backend to be available", backend)
+		}
+		set := NewMuHash()
+		for i, test := range testVectors {
+			set.Add(test.dataElement)
+			if !set.Finalize().IsEqual(&test.cumulativeHash) {
+				t.Fatalf("Backend %s, test #%d: Expected %s == %s", backend, i, set.Finalize(), test.cumulativeHash)
+			}
+		}
+		for _, test := range testVectors {
+			set.Remove(test.dataElement)
+		}
+		if !set.Finalize().IsEqual(&EmptyMuHashHash) {
+			t.Fatalf("Backend %s: Expected %s == %s", backend, set.Finalize(), EmptyMuHashHash)
+		}
+	}
+	if fieldbackend.Set("unknown") {
+		t.Fatalf("Expected an unknown backend not to be available")
+	}
+}
+
+func TestMuHash_NegativeMultiplicity(t *testing.T) {
+	t.Parallel()
+	set := NewMuHash()
+	set.Add(elementFromByte(1))
+	setHash := set.Finalize()
+
+	// Remove elements that were never added, one of them twice.
+	set.Remove(elementFromByte(2))
+	set.Remove(elementFromByte(3))
+	set.Remove(elementFromByte(3))
+	negativeHash := set.Finalize()
+	if negativeHash.IsEqual(&setHash) || negativeHash.IsEqual(&EmptyMuHashHash) {
+		t.Fatalf("Expected removing elements that were never added to change the hash")
+	}
+
+	// The negative set behaves like any other set through normalizing and serializing.
+	set.Normalize()
+	deserialized, err := DeserializeMuHash(set.Serialize())
+	if err != nil {
+		t.Fatalf("Failed deserializing a set with negative multiplicities: %v", err)
+	}
+	if !deserialized.Finalize().IsEqual(&negativeHash) {
+		t.Fatalf("Expected %s == %s", deserialized.Finalize(), negativeHash)
+	}
+
+	deserialized.Add(elementFromByte(3))
+	deserialized.Add(elementFromByte(2))
+	deserialized.Add(elementFromByte(3))
+	if !deserialized.Finalize().IsEqual(&setHash) {
+		t.Fatalf("Expected adding the removed elements to cancel the removals: %s == %s", deserialized.Finalize(), setHash)
+	}
+
+	// Removing every element results in the empty set.
+	deserialized.Remove(elementFromByte(1))
+	if !deserialized.Finalize().IsEqual(&EmptyMuHashHash) {
+		t.Fatalf("Expected %s == %s", deserialized.Finalize(), EmptyMuHashHash)
+	}
+}
+
+func TestMuHash_CombineSelf(t *testing.T) {
+	t.Parallel()
+	set := NewMuHash()
+	doubled := NewMuHash()
+	for i := byte(0); i < 10; i++ {
+		if i%3 == 0 {
+			set.Remove(elementFromByte(i))
+			doubled.Remove(elementFromByte(i))
+			doubled.Remove(elementFromByte(i))
+		} else {
+			set.Add(elementFromByte(i))
+			doubled.Add(elementFromByte(i))
+			doubled.Add(elementFromByte(i))
+		}
+	}
+	withClone := set.Clone()
+	withClone.Combine(set.Clone())
+
+	set.Combine(set)
+	doubledHash := doubled.Finalize()
+	if !set.Finalize().IsEqual(&doubledHash) {
+		t.Fatalf("Expected combining a set with itself to double its elements: %s == %s", set.Finalize(), doubledHash)
+	}
+	if !withClone.Finalize().IsEqual(&doubledHash) {
+		t.Fatalf("Expected %s == %s", withClone.Finalize(), doubledHash)
+	}
+}
+
+func TestMuHash_Double(t *testing.T) {
+	t.Parallel()
+	set := NewMuHash()
+	set.Add(elementFromByte(1))
+	set.Remove(elementFromByte(2))
+	combined := set.Clone()
+	combined.Combine(set.Clone())
+	squared := set.Clone()
+	squared.numerator.Square()
+	squared.denominator.Square()
+
+	set.Double()
+	if set.Finalize() != combined.Finalize() {
+		t.Fatalf("Expected %s == %s", set.Finalize(), combined.Finalize())
+	}
+	if set.Finalize() != squared.Finalize() {
+		t.Fatalf("Expected Double to match Square: %s == %s", set.Finalize(), squared.Finalize())
+	}
+}
+
+func TestMuHash_CombineChecked(t *testing.T) {
+	t.Parallel()
+	set := NewMuHash()
+	set.Add(elementFromByte(1))
+	other := NewMuHash()
+	other.Add(elementFromByte(2))
+	other.Remove(elementFromByte(3))
+
+	expected := set.Clone()
+	expected.Combine(other)
+	err := set.CombineChecked(other)
+	if err != nil {
+		t.Fatalf("Failed combining muhash: %v", err)
+	}
+	if set.Finalize() != expected.Finalize() {
+		t.Fatalf("Expected %s == %s", set.Finalize(), expected.Finalize())
+	}
+
+	var overflown uint3072
+	for i := range overflown {
+		overflown[i] = maxUint
+	}
+	before := set.Finalize()
+	for _, bad := range []*MuHash{
+		{numerator: overflown, denominator: one()},
+		{numerator: one(), denominator: overflown},
+	} {
+		err = set.CombineChecked(bad)
+		if !errors.Is(err, ErrOverflow) {
+			t.Fatalf("Expected %s, instead found: %v", ErrOverflow, err)
+		}
+		if set.Finalize() != before {
+			t.Fatalf("Expected CombineChecked not to modify the set on error")
+		}
+	}
+}
+
+func TestMuHash_CombineDomains(t *testing.T) {
+	utxos := NewMuHashWithDomain([]byte("utxo"))
+	utxos.Add(elementFromByte(1))
+	otherUTXOs := NewMuHashWithDomain([]byte("utxo"))
+	otherUTXOs.Add(elementFromByte(2))
+	if err := utxos.CombineChecked(otherUTXOs); err != nil {
+		t.Fatalf("Expected sets with the same domain to combine, instead found: %v", err)
+	}
+	if !bytes.Equal(utxos.Domain(), []byte("utxo")) {
+		t.Fatalf("Expected domain %q, found %q", "utxo", utxos.Domain())
+	}
+	if NewMuHash().Domain() != nil || NewMuHashWithDomain(nil).Domain() != nil {
+		t.Fatalf("Expected sets without a domain to have a nil domain")
+	}
+	deserialized, err := DeserializeMuHashWithDomain(utxos.Serialize(), []byte("utxo"))
+	if err != nil {
+		t.Fatalf("Failed deserializing muhash: %v", err)
+	}
+	deserialized.Combine(otherUTXOs)
+
+	before := utxos.Finalize()
+	for _, other := range []*MuHash{NewMuHash(), NewMuHashWithDomain([]byte("headers"))} {
+		err := utxos.CombineChecked(other)
+		if !errors.Is(err, ErrDomainMismatch) {
+			t.Fatalf("Expected %s, instead found: %v", ErrDomainMismatch, err)
+		}
+		if utxos.Finalize() != before {
+			t.Fatalf("Expected CombineChecked not to modify the set on error")
+		}
+		err = other.CombineChecked(utxos)
+		if !errors.Is(err, ErrDomainMismatch) {
+			t.Fatalf("Expected %s, instead found: %v", ErrDomainMismatch, err)
+		}
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Fatalf("Expected Combine to panic when combining different domains")
+				}
+			}()
+			utxos.Combine(other)
+		}()
+	}
+
+	// Sets without a domain combine freely, and the domain isn't part of the serialized form.
+	plain := NewMuHash()
+	plain.Combine(NewMuHashWithDomain([]byte{}))
+	plain.Combine(NewMuHashWithHasher(sha256.Sum256))
+	if !NewMuHashWithDomain([]byte("utxo")).Serialize().Equal(plain.Serialize()) {
+		t.Fatalf("Expected the domain not to be serialized")
+	}
+
+	sets := make([]*MuHash, 4*minSetsPerWorker)
+	expected := NewMuHashWithDomain([]byte("utxo"))
+	for i := range sets {
+		sets[i] = NewMuHashWithDomain([]byte("utxo"))
+		sets[i].Add(elementFromByte(byte(i)))
+		expected.Add(elementFromByte(byte(i)))
+	}
+	combined := ParallelCombine(sets, 4)
+	if combined.Finalize() != expected.Finalize() {
+		t.Fatalf("Expected %s == %s", combined.Finalize(), expected.Finalize())
+	}
+
+	shouldPanic := func(name string, f func()) {
+		defer func() {
+			err, ok := recover().(error)
+			if !ok || !errors.Is(err, ErrDomainMismatch) {
+				t.Fatalf("Expected %s to panic with %s, instead found: %v", name, ErrDomainMismatch, err)
+			}
+		}()
+		f()
+	}
+	// A single set with another domain among enough sets to use the workers must panic on this goroutine.
+	sets[len(sets)-1] = NewMuHashWithDomain([]byte("headers"))
+	shouldPanic("ParallelCombine with mixed domains", func() { ParallelCombine(sets, 4) })
+	sets[len(sets)-1] = NewMuHash()
+	shouldPanic("ParallelCombine with a set without a domain", func() { ParallelCombine(sets, 4) })
+	shouldPanic("Diff with different domains", func() { utxos.Diff(NewMuHash()) })
+	if diff := utxos.Diff(otherUTXOs); !bytes.Equal(diff.Domain(), []byte("utxo")) {
+		t.Fatalf("Expected the diff to keep the domain, found %q", diff.Domain())
+	}
+}
+
+func TestMuHash_CombineSerialized(t *testing.T) {
+	set := NewMuHash()
+	set.Add(elementFromByte(1))
+	set.Remove(elementFromByte(2))
+	other := NewMuHash()
+	other.Add(elementFromByte(3))
+	other.Remove(elementFromByte(4))
+	serialized := other.Serialize()
+
+	deserialized, err := DeserializeMuHash(serialized)
+	if err != nil {
+		t.Fatalf("Failed deserializing muhash: %v", err)
+	}
+	expected := set.Clone()
+	expected.Combine(deserialized)
 
-	overflow, err := hex.DecodeString("ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff")
+	err = set.CombineSerialized(serialized)
 	if err != nil {
-		t.Fatalf("Failed deserializing hex string: %v", err)
+		t.Fatalf("Failed combining serialized muhash: %v", err)
+	}
+	if set.Finalize() != expected.Finalize() {
+		t.Fatalf("Expected %s == %s", set.Finalize(), expected.Finalize())
 	}
 
-	if copy(serialized[:], overflow) != len(overflow) {
-		t.Fatalf("Failed copying %x into SerializedMuHash", overflow)
+	before := set.Finalize()
+	var overflow SerializedMuHash
+	copy(overflow[:], bytes.Repeat([]byte{0xff}, SerializedMuHashSize))
+	err = set.CombineSerialized(&overflow)
+	if !errors.Is(err, ErrOverflow) {
+		t.Fatalf("Expected %s, instead found: %v", ErrOverflow, err)
+	}
+	if set.Finalize() != before {
+		t.Fatalf("Expected CombineSerialized not to modify the set on error")
 	}
 
-	_, err = DeserializeMuHash(serialized)
-	if !errors.Is(err, errOverflow) {
-		t.Fatalf("Expected %s, instead found: %s", errOverflow, err)
+	if !raceEnabled {
+		allocs := testing.AllocsPerRun(10, func() {
+			_ = set.CombineSerialized(serialized)
+		})
+		if allocs != 0 {
+			t.Fatalf("Expected CombineSerialized not to allocate, found %f allocations per run", allocs)
+		}
 	}
+}
 
-	serializedZeros := SerializedMuHash{}
-	zeroed := NewMuHash()
-	zeroed.addElement(&num3072{}) // multiply by zero.
-	serialized = zeroed.Serialize()
-	if !bytes.Equal(serialized[:], serializedZeros[:]) {
-		t.Fatalf("expected serialized to be all zeros, instead found: %s", serialized)
+func TestMuHash_RemoveSerialized(t *testing.T) {
+	set := NewMuHash()
+	set.Add(elementFromByte(1))
+	set.Remove(elementFromByte(2))
+	other := NewMuHash()
+	other.Add(elementFromByte(3))
+	other.Remove(elementFromByte(4))
+	serialized := other.Serialize()
+
+	deserialized, err := DeserializeMuHash(serialized)
+	if err != nil {
+		t.Fatalf("Failed deserializing muhash: %v", err)
+	}
+	expected := set.Diff(deserialized)
+	original := set.Finalize()
+
+	err = set.RemoveSerialized(serialized)
+	if err != nil {
+		t.Fatalf("Failed removing serialized muhash: %v", err)
 	}
-	deserialized, err = DeserializeMuHash(serialized)
+	if set.Finalize() != expected.Finalize() {
+		t.Fatalf("Expected %s == %s", set.Finalize(), expected.Finalize())
+	}
+	err = set.CombineSerialized(serialized)
 	if err != nil {
-		t.Fatalf("Failed deserializing zeros: %v", err)
+		t.Fatalf("Failed combining serialized muhash: %v", err)
 	}
-	zeroed.normalize()
-	deserialized.normalize()
-	if zeroed.numerator != deserialized.numerator {
-		t.Fatalf("Expected %x == %x", zeroed.numerator, deserialized.numerator)
+	if set.Finalize() != original {
+		t.Fatalf("Expected CombineSerialized to undo RemoveSerialized: %s == %s", set.Finalize(), original)
+	}
+
+	var overflow SerializedMuHash
+	copy(overflow[:], bytes.Repeat([]byte{0xff}, SerializedMuHashSize))
+	if err := set.RemoveSerialized(&overflow); !errors.Is(err, ErrOverflow) {
+		t.Fatalf("Expected %s, instead found: %v", ErrOverflow, err)
+	}
+	if err := set.RemoveSerialized(&SerializedMuHash{}); !errors.Is(err, ErrZeroMuHash) {
+		t.Fatalf("Expected %s, instead found: %v", ErrZeroMuHash, err)
+	}
+	if set.Finalize() != original {
+		t.Fatalf("Expected RemoveSerialized not to modify the set on error")
+	}
+
+	if !raceEnabled {
+		allocs := testing.AllocsPerRun(10, func() {
+			_ = set.RemoveSerialized(serialized)
+		})
+		if allocs != 0 {
+			t.Fatalf("Expected RemoveSerialized not to allocate, found %f allocations per run", allocs)
+		}
 	}
 }
 
-func TestVectorsMuHash_Hash(t *testing.T) {
+func TestMuHash_Diff(t *testing.T) {
 	t.Parallel()
-	for _, test := range testVectors {
-		m := NewMuHash()
-		m.Add(test.dataElement)
-		mFinal := m.Finalize()
-		if !m.Finalize().IsEqual(&test.multisetHash) {
-			t.Errorf("MuHash-Hash returned incorrect hash serialization, expected: '%s', found: '%s'", test.multisetHash, mFinal)
-		}
+	a, b, onlyA := NewMuHash(), NewMuHash(), NewMuHash()
+	for i := 0; i < 10; i++ {
+		a.Add(elementFromByte(byte(i)))
+		onlyA.Add(elementFromByte(byte(i)))
 	}
-	m := NewMuHash()
-	if !m.Finalize().IsEqual(&EmptyMuHashHash) {
-		t.Fatalf("Empty set did not return zero hash, got: '%s' instead", m.Finalize())
+	// b shares some of a's elements and has some of its own.
+	for i := 5; i < 15; i++ {
+		b.Add(elementFromByte(byte(i)))
+		onlyA.Remove(elementFromByte(byte(i)))
+	}
+	b.Remove(elementFromByte(20))
+	onlyA.Add(elementFromByte(20))
+	aHash, bHash := a.Finalize(), b.Finalize()
+
+	diff := a.Diff(b)
+	if diff.Finalize() != onlyA.Finalize() {
+		t.Fatalf("Expected %s == %s", diff.Finalize(), onlyA.Finalize())
+	}
+	diff.Combine(b)
+	if diff.Finalize() != aHash {
+		t.Fatalf("Expected %s == %s", diff.Finalize(), aHash)
+	}
+	if a.Finalize() != aHash || b.Finalize() != bHash {
+		t.Fatalf("Diff shouldn't modify its sets")
+	}
+
+	if !a.Diff(a).IsEmpty() {
+		t.Fatalf("Expected the diff of a set with itself to be empty, found %s", a.Diff(a).Finalize())
 	}
 }
 
-func TestVectorsMuHash_AddRemove(t *testing.T) {
+func TestDeltaSerialized(t *testing.T) {
 	t.Parallel()
-	m := NewMuHash()
-	for i, test := range testVectors {
-		m.Add(test.dataElement)
-		mFinal := m.Finalize()
-		if !mFinal.IsEqual(&test.cumulativeHash) {
-			t.Errorf("Test #%d: MuHash-Add returned incorrect hash. Expected '%s' but got '%s'", i, test.cumulativeHash, mFinal)
-		}
+	from, to, delta := NewMuHash(), NewMuHash(), NewMuHash()
+	for i := 0; i < 10; i++ {
+		from.Add(elementFromByte(byte(i)))
+		to.Add(elementFromByte(byte(i)))
+	}
+	// Between the snapshots some elements were spent and some were created.
+	for i := 0; i < 3; i++ {
+		to.Remove(elementFromByte(byte(i)))
+		delta.Remove(elementFromByte(byte(i)))
+	}
+	for i := 10; i < 15; i++ {
+		to.Add(elementFromByte(byte(i)))
+		delta.Add(elementFromByte(byte(i)))
 	}
 
-	for i := len(testVectors) - 1; i > 0; i-- {
-		m.Remove(testVectors[i].dataElement)
-		mFinal := m.Finalize()
-		if !mFinal.IsEqual(&testVectors[i-1].cumulativeHash) {
-			t.Errorf("Test #%d: MuHash-Remove returned incorrect hash. Expected '%s' but got '%s'", i, testVectors[i].cumulativeHash, mFinal)
-		}
+	result, err := DeltaSerialized(from.Serialize(), to.Serialize())
+	if err != nil {
+		t.Fatalf("DeltaSerialized: %v", err)
+	}
+	if result.Finalize() != delta.Finalize() {
+		t.Fatalf("Expected %s == %s", result.Finalize(), delta.Finalize())
+	}
+	result.Combine(from)
+	if result.Finalize() != to.Finalize() {
+		t.Fatalf("Expected %s == %s", result.Finalize(), to.Finalize())
+	}
+
+	var overflow SerializedMuHash
+	for i := range overflow {
+		overflow[i] = 0xff
+	}
+	if _, err := DeltaSerialized(&overflow, to.Serialize()); !errors.Is(err, ErrOverflow) {
+		t.Fatalf("Expected %s, instead found: %v", ErrOverflow, err)
+	}
+	if _, err := DeltaSerialized(from.Serialize(), &overflow); !errors.Is(err, ErrOverflow) {
+		t.Fatalf("Expected %s, instead found: %v", ErrOverflow, err)
 	}
 }
 
-func TestVectorsMuHash_CombineSubtract(t *testing.T) {
+func TestParallelCombine(t *testing.T) {
 	t.Parallel()
-	m1 := NewMuHash()
-	zeroHash := m1.Finalize()
-
-	for _, test := range testVectors {
-		m1.Add(test.dataElement)
+	r := rand.New(rand.NewSource(2))
+	sets := make([]*MuHash, 100)
+	for i := range sets {
+		sets[i] = NewMuHash()
+		data := [100]byte{}
+		n, err := r.Read(data[:])
+		if err != nil || n != len(data) {
+			t.Fatalf("Failed generating random data. read: '%d' bytes. .'%v'", n, err)
+		}
+		if i%3 == 0 {
+			sets[i].Remove(data[:])
+		} else {
+			sets[i].Add(data[:])
+		}
+	}
+	before := make([]MuHash, len(sets))
+	for i := range sets {
+		before[i] = *sets[i]
 	}
 
-	m2 := NewMuHash()
-	for _, test := range testVectors {
-		m2.Remove(test.dataElement)
+	for _, n := range []int{0, 1, 15, 16, 33, len(sets)} {
+		serial := NewMuHash()
+		serial.CombineAll(sets[:n]...)
+		expected := serial.Finalize()
+		for _, workers := range []int{-1, 0, 1, 2, 3, 7, 200} {
+			combined := ParallelCombine(sets[:n], workers)
+			if !combined.Finalize().IsEqual(&expected) {
+				t.Fatalf("%d sets with %d workers: Expected %s == %s", n, workers, combined.Finalize(), expected)
+			}
+		}
 	}
-	m1.Combine(m2)
-	if !m1.Finalize().IsEqual(&zeroHash) {
-		t.Fatalf("m1 was expected to have a zero hash, but was '%s' instead", m1.Finalize())
+	for i := range sets {
+		if *sets[i] != before[i] {
+			t.Fatalf("ParallelCombine modified set #%d", i)
+		}
 	}
 }
 
@@ -362,6 +2515,39 @@ func TestMuHash_Reset(t *testing.T) {
 	}
 }
 
+func TestMuHash_IsEmpty(t *testing.T) {
+	t.Parallel()
+	set := NewMuHash()
+	if !set.IsEmpty() {
+		t.Fatalf("A new set should be empty")
+	}
+	for _, test := range testVectors {
+		set.Add(test.dataElement)
+		if set.IsEmpty() || set.Finalize().IsEqual(&EmptyMuHashHash) {
+			t.Fatalf("A set with elements shouldn't be empty: %s", set)
+		}
+	}
+	for _, test := range testVectors {
+		set.Remove(test.dataElement)
+	}
+	if !set.IsEmpty() || !set.Finalize().IsEqual(&EmptyMuHashHash) {
+		t.Fatalf("A set with all elements removed should be empty: %s", set)
+	}
+
+	// A non normalized set which is equal to one.
+	overflown := maxMuHash
+	overflown.numerator[0] -= primeDiff - 1 // The prime itself
+	overflown.denominator = uint3072{}
+	if overflown.IsEmpty() {
+		t.Fatalf("A zero set isn't empty")
+	}
+	overflown.numerator = maxMuHash.numerator
+	overflown.denominator = uint3072{primeDiff - 1}
+	if !overflown.IsEmpty() || !overflown.Finalize().IsEqual(&EmptyMuHashHash) {
+		t.Fatalf("Expected %s to be empty", overflown.Finalize())
+	}
+}
+
 const loopsN = 1024
 
 func TestMuHashAddRemove(t *testing.T) {
@@ -391,6 +2577,196 @@ func TestMuHashAddRemove(t *testing.T) {
 	}
 }
 
+func TestMuHash_ElementWriter(t *testing.T) {
+	t.Parallel()
+	for i, test := range testVectors {
+		expected := NewMuHash()
+		expected.Add(test.dataElement)
+		expectedHash := expected.Finalize()
+
+		for _, chunkSize := range []int{1, 7, 64, len(test.dataElement)} {
+			m := NewMuHash()
+			writer := m.ElementWriter()
+			for start := 0; start < len(test.dataElement); start += chunkSize {
+				end := start + chunkSize
+				if end > len(test.dataElement) {
+					end = len(test.dataElement)
+				}
+				n, err := writer.Write(test.dataElement[start:end])
+				if err != nil || n != end-start {
+					t.Fatalf("Test #%d: failed writing to ElementWriter. wrote: '%d' bytes. '%v'", i, n, err)
+				}
+			}
+			err := writer.Close()
+			if err != nil {
+				t.Fatalf("Test #%d: failed closing ElementWriter: %v", i, err)
+			}
+			if !m.Finalize().IsEqual(&expectedHash) {
+				t.Fatalf("Test #%d: chunk size %d: Expected %s == %s", i, chunkSize, m.Finalize(), expectedHash)
+			}
+			_, err = writer.Write(test.dataElement)
+			if !errors.Is(err, errWriterClosed) {
+				t.Fatalf("Expected %s, instead found: %v", errWriterClosed, err)
+			}
+			err = writer.Close()
+			if !errors.Is(err, errWriterClosed) {
+				t.Fatalf("Expected %s, instead found: %v", errWriterClosed, err)
+			}
+		}
+	}
+}
+
+func TestNewMuHashWithHasher(t *testing.T) {
+	t.Parallel()
+	blake2bHasher := func(data []byte) [32]byte {
+		blake, err := blake2b.New256([]byte("MuHashElement"))
+		if err != nil {
+			t.Fatalf("Failed creating blake2b: %v", err)
+		}
+		blake.Write(data)
+		var res [32]byte
+		blake.Sum(res[:0])
+		return res
+	}
+	for i, test := range testVectors {
+		m := NewMuHashWithHasher(blake2bHasher)
+		m.Add(test.dataElement)
+		if !m.Finalize().IsEqual(&test.multisetHash) {
+			t.Fatalf("Test #%d: Expected %s == %s", i, m.Finalize(), test.multisetHash)
+		}
+	}
+
+	set := NewMuHashWithHasher(sha256.Sum256)
+	set.Add(elementFromByte(1))
+	set.Remove(elementFromByte(2))
+	defaultSet := NewMuHash()
+	defaultSet.Add(elementFromByte(1))
+	defaultSet.Remove(elementFromByte(2))
+	defaultHash := defaultSet.Finalize()
+	if set.Finalize().IsEqual(&defaultHash) {
+		t.Fatalf("Sets with different element hashers shouldn't have the same hash")
+	}
+
+	// The hasher should survive cloning, resetting, deserializing and the element writer.
+	expected := set.Finalize()
+	clone := set.Clone()
+	clone.Reset()
+	clone.Add(elementFromByte(1))
+	writer := clone.ElementWriter()
+	_, err := writer.Write(elementFromByte(2)[:10])
+	if err != nil {
+		t.Fatalf("Failed writing to ElementWriter: %v", err)
+	}
+	_, err = writer.Write(elementFromByte(2)[10:])
+	if err != nil {
+		t.Fatalf("Failed writing to ElementWriter: %v", err)
+	}
+	err = writer.Close()
+	if err != nil {
+		t.Fatalf("Failed closing ElementWriter: %v", err)
+	}
+	clone.Remove(elementFromByte(2))
+	clone.Remove(elementFromByte(2))
+	if !clone.Finalize().IsEqual(&expected) {
+		t.Fatalf("Expected %s == %s", clone.Finalize(), expected)
+	}
+
+	deserialized, err := DeserializeMuHashWithHasher(set.Serialize(), sha256.Sum256)
+	if err != nil {
+		t.Fatalf("Failed deserializing muhash: %v", err)
+	}
+	deserialized.Remove(elementFromByte(1))
+	deserialized.Add(elementFromByte(2))
+	if !deserialized.Finalize().IsEqual(&EmptyMuHashHash) {
+		t.Fatalf("Expected %s == %s", deserialized.Finalize(), EmptyMuHashHash)
+	}
+}
+
+func TestNewMuHashWithDomain(t *testing.T) {
+	// An empty tag results in the default elements.
+	for i, test := range testVectors {
+		for _, tag := range [][]byte{nil, {}} {
+			m := NewMuHashWithDomain(tag)
+			m.Add(test.dataElement)
+			if !m.Finalize().IsEqual(&test.multisetHash) {
+				t.Fatalf("Test #%d: Expected %s == %s", i, m.Finalize(), test.multisetHash)
+			}
+		}
+	}
+
+	// The element is derived from the length prefixed tag followed by the data.
+	data := elementFromByte(1)
+	utxos := NewMuHashWithDomain([]byte("utxo"))
+	utxos.Add(data)
+	reference := newBigMuHash()
+	reference.Add(append([]byte{4, 'u', 't', 'x', 'o'}, data...))
+	if utxos.Finalize() != reference.Finalize() {
+		t.Fatalf("Expected %s == %s", utxos.Finalize(), reference.Finalize())
+	}
+
+	// The same data results in different elements with different tags.
+	hashes := make(map[Hash][]byte)
+	for _, tagAndData := range [][2][]byte{
+		{nil, []byte("bc")},
+		{[]byte("a"), []byte("bc")},
+		{[]byte("ab"), []byte("c")},
+		{[]byte("utxo"), []byte("bc")},
+		{[]byte("header"), []byte("bc")},
+		{[]byte("\x02ab"), []byte("c")},
+	} {
+		m := NewMuHashWithDomain(tagAndData[0])
+		m.Add(tagAndData[1])
+		if other, ok := hashes[m.Finalize()]; ok {
+			t.Fatalf("The tags %q and %q result in the same element", tagAndData[0], other)
+		}
+		hashes[m.Finalize()] = tagAndData[0]
+	}
+
+	// The tag is copied, so changing it afterwards doesn't affect the set.
+	tag := []byte("utxo")
+	copied := NewMuHashWithDomain(tag)
+	tag[0] = 'U'
+	copied.Add(data)
+	if copied.Finalize() != utxos.Finalize() {
+		t.Fatalf("Expected %s == %s", copied.Finalize(), utxos.Finalize())
+	}
+
+	// The tag should survive cloning, deserializing and the element writer.
+	clone := utxos.Clone()
+	writer := clone.ElementWriter()
+	_, err := writer.Write(data)
+	if err != nil {
+		t.Fatalf("Failed writing to ElementWriter: %v", err)
+	}
+	err = writer.Close()
+	if err != nil {
+		t.Fatalf("Failed closing ElementWriter: %v", err)
+	}
+	clone.Remove(data)
+	clone.Remove(data)
+	if !clone.IsEmpty() {
+		t.Fatalf("Expected the element writer to use the domain tag")
+	}
+	deserialized, err := DeserializeMuHashWithDomain(utxos.Serialize(), []byte("utxo"))
+	if err != nil {
+		t.Fatalf("Failed deserializing muhash: %v", err)
+	}
+	deserialized.Remove(data)
+	if !deserialized.Finalize().IsEqual(&EmptyMuHashHash) {
+		t.Fatalf("Expected %s == %s", deserialized.Finalize(), EmptyMuHashHash)
+	}
+
+	if !raceEnabled {
+		allocs := testing.AllocsPerRun(10, func() {
+			utxos.Add(data)
+			utxos.Remove(data)
+		})
+		if allocs != 0 {
+			t.Fatalf("Expected Add and Remove with a domain not to allocate, found %f allocations per run", allocs)
+		}
+	}
+}
+
 func TestHash_IsEqual(t *testing.T) {
 	t.Parallel()
 	r := rand.New(rand.NewSource(1))
@@ -450,6 +2826,62 @@ func TestHash_SetBytes(t *testing.T) {
 	}
 }
 
+func TestHash_MarshalText(t *testing.T) {
+	t.Parallel()
+	text, err := EmptyMuHashHash.MarshalText()
+	if err != nil {
+		t.Fatalf("Failed marshaling hash: %v", err)
+	}
+	if string(text) != EmptyMuHashHash.String() {
+		t.Fatalf("Expected %s == %s", text, EmptyMuHashHash)
+	}
+	var hash Hash
+	err = hash.UnmarshalText(text)
+	if err != nil {
+		t.Fatalf("Failed unmarshaling hash: %v", err)
+	}
+	if !hash.IsEqual(&EmptyMuHashHash) {
+		t.Fatalf("Expected %s == %s", hash, EmptyMuHashHash)
+	}
+
+	err = hash.UnmarshalText(text[:len(text)-2])
+	if err == nil {
+		t.Fatalf("Hash.UnmarshalText should fail on shorter text")
+	}
+	text[0] = 'z'
+	err = hash.UnmarshalText(text)
+	if err == nil {
+		t.Fatalf("Hash.UnmarshalText should fail on non hex text")
+	}
+	if !hash.IsEqual(&EmptyMuHashHash) {
+		t.Fatalf("A failed Hash.UnmarshalText shouldn't modify the hash, found: %s", hash)
+	}
+}
+
+func TestHashFromString(t *testing.T) {
+	t.Parallel()
+	hash, err := HashFromString(EmptyMuHashHash.String())
+	if err != nil {
+		t.Fatalf("Failed parsing hash: %v", err)
+	}
+	if !hash.IsEqual(&EmptyMuHashHash) {
+		t.Fatalf("Expected %s == %s", hash, EmptyMuHashHash)
+	}
+
+	invalid := []string{
+		"",
+		EmptyMuHashHash.String()[2:],
+		EmptyMuHashHash.String() + "00",
+		"zz" + EmptyMuHashHash.String()[2:],
+	}
+	for _, s := range invalid {
+		_, err = HashFromString(s)
+		if err == nil {
+			t.Fatalf("HashFromString should fail on '%s'", s)
+		}
+	}
+}
+
 func BenchmarkMuHash_Add(b *testing.B) {
 	set := NewMuHash()
 	var data [100]byte
@@ -499,9 +2931,9 @@ func BenchmarkMuHash_CombineRand(b *testing.B) {
 	r := rand.New(rand.NewSource(0))
 	set := NewMuHash()
 	var element MuHash
-	for i := range element.numerator.limbs {
-		element.numerator.limbs[i] = word(r.Uint64())
-		element.denominator.limbs[i] = word(r.Uint64())
+	for i := range element.numerator {
+		element.numerator[i] = uint(r.Uint64())
+		element.denominator[i] = uint(r.Uint64())
 	}
 	b.ReportAllocs()
 	b.ResetTimer()
@@ -510,6 +2942,47 @@ func BenchmarkMuHash_CombineRand(b *testing.B) {
 	}
 }
 
+// BenchmarkMuHash_Double and BenchmarkMuHash_DoubleSquare compare doubling a set with Mul, which Double uses,
+// and with Square.
+func BenchmarkMuHash_Double(b *testing.B) {
+	set := maxMuHash
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		set.Double()
+	}
+}
+
+func BenchmarkMuHash_DoubleSquare(b *testing.B) {
+	set := maxMuHash
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		set.numerator.Square()
+		set.denominator.Square()
+	}
+}
+
+func BenchmarkParallelCombine(b *testing.B) {
+	r := rand.New(rand.NewSource(0))
+	sets := make([]*MuHash, 1024)
+	for i := range sets {
+		sets[i] = NewMuHash()
+		for j := range sets[i].numerator {
+			sets[i].numerator[j] = uint(r.Uint64())
+			sets[i].denominator[j] = uint(r.Uint64())
+		}
+	}
+	for workers := 1; workers <= runtime.NumCPU(); workers *= 2 {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				ParallelCombine(sets, workers)
+			}
+		})
+	}
+}
+
 func BenchmarkMuHash_Clone(b *testing.B) {
 	b.ReportAllocs()
 	for i := 0; i < b.N; i++ {
@@ -520,7 +2993,7 @@ func BenchmarkMuHash_Clone(b *testing.B) {
 func BenchmarkMuHash_normalizeWorst(b *testing.B) {
 	b.ReportAllocs()
 	set := maxMuHash
-	set.denominator.limbs[0]--
+	set.denominator[0]--
 	for i := 0; i < b.N; i++ {
 		set.Clone().normalize()
 	}
@@ -538,9 +3011,9 @@ func BenchmarkMuHash_normalizeBest(b *testing.B) {
 func BenchmarkMuHash_normalizeRand(b *testing.B) {
 	r := rand.New(rand.NewSource(0))
 	var set MuHash
-	for i := range set.numerator.limbs {
-		set.numerator.limbs[i] = word(r.Uint64())
-		set.denominator.limbs[i] = word(r.Uint64())
+	for i := range set.numerator {
+		set.numerator[i] = uint(r.Uint64())
+		set.denominator[i] = uint(r.Uint64())
 	}
 
 	b.ReportAllocs()
@@ -550,12 +3023,52 @@ func BenchmarkMuHash_normalizeRand(b *testing.B) {
 	}
 }
 
+func BenchmarkNormalizeBatch(b *testing.B) {
+	r := rand.New(rand.NewSource(0))
+	sets := make([]MuHash, 100)
+	for i := range sets {
+		for j := range sets[i].numerator {
+			sets[i].numerator[j] = uint(r.Uint64())
+			sets[i].denominator[j] = uint(r.Uint64())
+		}
+	}
+	clones := make([]*MuHash, len(sets))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for j := range sets {
+			clones[j] = sets[j].Clone()
+		}
+		NormalizeBatch(clones)
+	}
+}
+
+func BenchmarkNormalizeAll(b *testing.B) {
+	r := rand.New(rand.NewSource(0))
+	sets := make([]MuHash, 1000)
+	for i := range sets {
+		for j := range sets[i].numerator {
+			sets[i].numerator[j] = uint(r.Uint64())
+			sets[i].denominator[j] = uint(r.Uint64())
+		}
+	}
+	clones := make([]*MuHash, len(sets))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for j := range sets {
+			clones[j] = sets[j].Clone()
+		}
+		NormalizeAll(clones, 0)
+	}
+}
+
 func BenchmarkMuHash_Finalize(b *testing.B) {
 	r := rand.New(rand.NewSource(0))
 	var set MuHash
-	for i := range set.numerator.limbs {
-		set.numerator.limbs[i] = word(r.Uint64())
-		set.denominator.limbs[i] = word(r.Uint64())
+	for i := range set.numerator {
+		set.numerator[i] = uint(r.Uint64())
+		set.denominator[i] = uint(r.Uint64())
 	}
 	b.ResetTimer()
 	b.ReportAllocs()
@@ -563,3 +3076,20 @@ func BenchmarkMuHash_Finalize(b *testing.B) {
 		set.Clone().Finalize()
 	}
 }
+
+func BenchmarkDeserializeReuse(b *testing.B) {
+	set := NewMuHash()
+	set.Add(elementFromByte(1))
+	set.Remove(elementFromByte(2))
+	serialized := set.Serialize()
+	reused := NewMuHash()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		reused.Reset()
+		err := reused.SetFromSerialized(serialized)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,25 @@
+//go:build js && wasm
+// +build js,wasm
+
+package muhash
+
+import "testing"
+
+func TestWasmMuHash(t *testing.T) {
+	t.Parallel()
+	expected := "b557f7cfc13cf9abc31374832715e7bff2cf5859897523337a0ead9dde012974"
+	set := NewMuHash()
+	set.Add(elementFromByte(0))
+	set.Add(elementFromByte(1))
+	set.Remove(elementFromByte(2))
+	if set.Finalize().String() != expected {
+		t.Fatalf("Expected %s == %s", expected, set.Finalize())
+	}
+
+	set.Remove(elementFromByte(0))
+	set.Remove(elementFromByte(1))
+	set.Add(elementFromByte(2))
+	if !set.Finalize().IsEqual(&EmptyMuHashHash) {
+		t.Fatalf("Expected %s == %s", set.Finalize(), EmptyMuHashHash)
+	}
+}
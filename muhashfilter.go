@@ -0,0 +1,102 @@
+package muhash
+
+import (
+	"encoding/binary"
+	"math"
+
+	"github.com/pkg/errors"
+)
+
+// MuHashWithFilter is a MuHash paired with a Bloom filter over the blake2b hashes of the added elements, so it can
+// tell whether data may already be in the set. The commitment is exact, the same as a MuHash's with the same elements
+// added and removed, while membership is probabilistic:
+//   - MayContain never returns false for data that was added, i.e. there are no false negatives.
+//   - It returns true for data that was never added with about the false positive rate the filter was sized for,
+//     as long as no more elements than it was sized for were added. Beyond that the rate grows.
+//   - A Bloom filter can't forget, so removed data keeps returning true, and removals don't lower the rate.
+//
+// The filter takes about 1.44 * log2(1/rate) bits per expected element, e.g. 1.2 bytes for a 1% rate.
+// Use NewMuHashWithFilter to initialize a MuHashWithFilter.
+type MuHashWithFilter struct {
+	inner     MuHash
+	filter    []uint64
+	hashCount uint64
+}
+
+// NewMuHashWithFilter returns an empty set whose filter is sized for expectedElements elements with the given false
+// positive rate, which must be in (0, 1).
+func NewMuHashWithFilter(expectedElements int, falsePositiveRate float64) (*MuHashWithFilter, error) {
+	if expectedElements <= 0 {
+		return nil, errors.Errorf("the expected number of elements must be positive, got %d", expectedElements)
+	}
+	if !(falsePositiveRate > 0 && falsePositiveRate < 1) {
+		return nil, errors.Errorf("the false positive rate must be between 0 and 1, got %f", falsePositiveRate)
+	}
+	// The optimal Bloom filter has -n*ln(rate)/ln(2)^2 bits and uses bits/n*ln(2) hash functions.
+	bitCount := math.Ceil(-float64(expectedElements) * math.Log(falsePositiveRate) / (math.Ln2 * math.Ln2))
+	hashCount := math.Max(1, math.Round(bitCount/float64(expectedElements)*math.Ln2))
+	return &MuHashWithFilter{
+		inner:     *NewMuHash(),
+		filter:    make([]uint64, (uint64(bitCount)+63)/64),
+		hashCount: uint64(hashCount),
+	}, nil
+}
+
+// Add hashes the data and adds it to the set and to the filter.
+func (set *MuHashWithFilter) Add(data []byte) {
+	hashed := elementHash(data)
+	set.forEachBit(&hashed, func(word int, bit uint64) bool {
+		set.filter[word] |= bit
+		return true
+	})
+	var element uint3072
+	hashToElement(&hashed, &element)
+	set.inner.addElement(&element)
+}
+
+// Remove hashes the data and removes it from the set. It stays in the filter, see MuHashWithFilter.
+func (set *MuHashWithFilter) Remove(data []byte) {
+	hashed := elementHash(data)
+	var element uint3072
+	hashToElement(&hashed, &element)
+	set.inner.removeElement(&element)
+}
+
+// MayContain returns false if the data was never added to the set, and true if it may have been,
+// see MuHashWithFilter for the false positive contract.
+func (set *MuHashWithFilter) MayContain(data []byte) bool {
+	hashed := elementHash(data)
+	mayContain := true
+	set.forEachBit(&hashed, func(word int, bit uint64) bool {
+		mayContain = set.filter[word]&bit != 0
+		return mayContain
+	})
+	return mayContain
+}
+
+// forEachBit calls fn with the position of each of the filter bits of the element hash, until fn returns false.
+// The positions are derived from the hash with double hashing, which performs about as well as independent hash
+// functions (Kirsch and Mitzenmacher, "Less Hashing, Same Performance").
+func (set *MuHashWithFilter) forEachBit(hashed *Hash, fn func(word int, bit uint64) bool) {
+	bitCount := uint64(len(set.filter)) * 64
+	h1 := binary.LittleEndian.Uint64(hashed[:8])
+	// An odd step never degenerates into setting the same bit k times.
+	h2 := binary.LittleEndian.Uint64(hashed[8:16]) | 1
+	for i := uint64(0); i < set.hashCount; i++ {
+		position := (h1 + i*h2) % bitCount
+		if !fn(int(position/64), 1<<(position%64)) {
+			return
+		}
+	}
+}
+
+// MuHash returns a copy of the set as a MuHash, e.g. to serialize it or to combine it with other sets.
+func (set *MuHashWithFilter) MuHash() *MuHash {
+	return set.inner.Clone()
+}
+
+// Finalize will return a hash(Blake2b) of the set. It's the same as the finalized hash of a MuHash with
+// the same elements added and removed.
+func (set *MuHashWithFilter) Finalize() Hash {
+	return set.inner.Finalize()
+}
@@ -0,0 +1,71 @@
+package muhash
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func TestMuHashWithFilter(t *testing.T) {
+	t.Parallel()
+	const elementsN = 1000
+	const falsePositiveRate = 0.01
+	set, err := NewMuHashWithFilter(elementsN, falsePositiveRate)
+	if err != nil {
+		t.Fatalf("NewMuHashWithFilter: %v", err)
+	}
+	expected := NewMuHash()
+	data := func(i int) []byte {
+		var encoded [8]byte
+		binary.LittleEndian.PutUint64(encoded[:], uint64(i))
+		return encoded[:]
+	}
+	for i := 0; i < elementsN; i++ {
+		set.Add(data(i))
+		expected.Add(data(i))
+	}
+	set.Remove(data(0))
+	expected.Remove(data(0))
+	if set.Finalize() != expected.Finalize() {
+		t.Fatalf("Expected %s == %s", set.Finalize(), expected.Finalize())
+	}
+	if !set.MuHash().Serialize().Equal(expected.Serialize()) {
+		t.Fatalf("Expected the MuHash of the set to equal a MuHash of its elements")
+	}
+
+	// No false negatives, even for removed data.
+	for i := 0; i < elementsN; i++ {
+		if !set.MayContain(data(i)) {
+			t.Fatalf("Expected the filter to contain element %d", i)
+		}
+	}
+	// The false positive rate is close to the one the filter was sized for.
+	var falsePositives int
+	const checksN = 10000
+	for i := elementsN; i < elementsN+checksN; i++ {
+		if set.MayContain(data(i)) {
+			falsePositives++
+		}
+	}
+	if rate := float64(falsePositives) / checksN; rate > 2*falsePositiveRate {
+		t.Fatalf("Expected a false positive rate of about %f, found %f", falsePositiveRate, rate)
+	}
+}
+
+func TestNewMuHashWithFilter_Invalid(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		expectedElements  int
+		falsePositiveRate float64
+	}{
+		{0, 0.01},
+		{-1, 0.01},
+		{100, 0},
+		{100, 1},
+		{100, -0.5},
+	}
+	for _, test := range tests {
+		if _, err := NewMuHashWithFilter(test.expectedElements, test.falsePositiveRate); err == nil {
+			t.Fatalf("Expected NewMuHashWithFilter(%d, %f) to fail", test.expectedElements, test.falsePositiveRate)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package muhash
+
+// MuHashSet is a MuHash with set semantics instead of multiset semantics: each element is counted at most once,
+// adding an element that is already in the set does nothing, and removing one that isn't does nothing.
+// Its finalized hash is the same as a MuHash's with each of its elements added once.
+//
+// To know which elements are in the set it keeps the blake2b hash of every element in a map,
+// which costs roughly 50-100 bytes of memory per element (32 bytes for the hash plus the map's overhead),
+// e.g. tens of megabytes for a million elements. Use MuHash if the elements are known to be unique.
+// Use NewMuHashSet to initialize a MuHashSet.
+type MuHashSet struct {
+	inner MuHash
+	seen  map[Hash]struct{}
+}
+
+// NewMuHashSet returns an empty initialized set.
+func NewMuHashSet() *MuHashSet {
+	return &MuHashSet{inner: *NewMuHash(), seen: make(map[Hash]struct{})}
+}
+
+// Add hashes the data and adds it to the set, unless it's already in the set.
+// Returns true if the data was added.
+func (set *MuHashSet) Add(data []byte) bool {
+	hashed := elementHash(data)
+	if _, ok := set.seen[hashed]; ok {
+		return false
+	}
+	set.seen[hashed] = struct{}{}
+	var element uint3072
+	hashToElement(&hashed, &element)
+	set.inner.addElement(&element)
+	return true
+}
+
+// Remove hashes the data and removes it from the set, if it's in the set.
+// Returns true if the data was removed.
+func (set *MuHashSet) Remove(data []byte) bool {
+	hashed := elementHash(data)
+	if _, ok := set.seen[hashed]; !ok {
+		return false
+	}
+	delete(set.seen, hashed)
+	var element uint3072
+	hashToElement(&hashed, &element)
+	set.inner.removeElement(&element)
+	return true
+}
+
+// Contains returns true if the data is in the set.
+func (set *MuHashSet) Contains(data []byte) bool {
+	_, ok := set.seen[elementHash(data)]
+	return ok
+}
+
+// Len returns the number of elements in the set.
+func (set *MuHashSet) Len() int {
+	return len(set.seen)
+}
+
+// MuHash returns a copy of the set as a MuHash, e.g. to serialize it or to combine it with other sets.
+func (set *MuHashSet) MuHash() *MuHash {
+	return set.inner.Clone()
+}
+
+// Finalize will return a hash(Blake2b) of the set. It's the same as the finalized hash of a MuHash with
+// each of the elements added once.
+func (set *MuHashSet) Finalize() Hash {
+	return set.inner.Finalize()
+}
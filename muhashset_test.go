@@ -0,0 +1,53 @@
+package muhash
+
+import "testing"
+
+func TestMuHashSet_Duplicates(t *testing.T) {
+	t.Parallel()
+	set := NewMuHashSet()
+	if !set.Add(elementFromByte(1)) || !set.Add(elementFromByte(2)) {
+		t.Fatalf("Expected new elements to be added")
+	}
+	if set.Add(elementFromByte(1)) {
+		t.Fatalf("Expected adding a duplicate element to be a no-op")
+	}
+	if set.Remove(elementFromByte(3)) {
+		t.Fatalf("Expected removing a missing element to be a no-op")
+	}
+	if set.Len() != 2 || !set.Contains(elementFromByte(1)) || set.Contains(elementFromByte(3)) {
+		t.Fatalf("Expected the set to contain exactly elements 1 and 2, found %d elements", set.Len())
+	}
+
+	expected := NewMuHash()
+	expected.Add(elementFromByte(1))
+	expected.Add(elementFromByte(2))
+	if set.Finalize() != expected.Finalize() {
+		t.Fatalf("Expected %s == %s", set.Finalize(), expected.Finalize())
+	}
+	if !set.MuHash().Serialize().Equal(expected.Serialize()) {
+		t.Fatalf("Expected the MuHash of the set to equal a MuHash of its elements")
+	}
+}
+
+func TestMuHashSet_AddRemove(t *testing.T) {
+	t.Parallel()
+	set := NewMuHashSet()
+	for i := byte(0); i < 10; i++ {
+		set.Add(elementFromByte(i))
+		set.Add(elementFromByte(i))
+	}
+	for i := byte(0); i < 10; i++ {
+		if !set.Remove(elementFromByte(i)) {
+			t.Fatalf("Expected element %d to be removed", i)
+		}
+		if set.Remove(elementFromByte(i)) {
+			t.Fatalf("Expected element %d to be removed only once", i)
+		}
+	}
+	if set.Len() != 0 {
+		t.Fatalf("Expected the set to be empty, found %d elements", set.Len())
+	}
+	if set.Finalize() != EmptyMuHashHash {
+		t.Fatalf("Expected %s == %s", set.Finalize(), EmptyMuHashHash)
+	}
+}
@@ -0,0 +1,77 @@
+// Package muhashtest provides fixtures for tests and benchmarks of code that uses muhash.
+// It's kept out of the muhash package so that none of it ships in production builds.
+package muhashtest
+
+import (
+	"math/rand"
+	"sync"
+
+	"github.com/kaspanet/go-muhash"
+	"github.com/kaspanet/go-muhash/internal/fieldbackend"
+	"github.com/pkg/errors"
+)
+
+// MaxMuHash returns a MuHash whose numerator and denominator have all their bits set,
+// the worst case input for Combine, Normalize and Serialize that the muhash benchmarks use.
+// It isn't a valid set, since 2^3072-1 isn't fully reduced, so it's only meant for benchmarks and tests.
+func MaxMuHash() *muhash.MuHash {
+	var raw [2 * muhash.SerializedMuHashSize]byte
+	for i := range raw {
+		raw[i] = 0xff
+	}
+	return muhash.DeserializeRaw(&raw)
+}
+
+// RandomMuHash returns a normalized MuHash with a random numerator and denominator drawn from a PRNG seeded with seed,
+// so it's a valid set whose value is the same for the same seed on every platform.
+// It's meant for reproducible fixtures in tests and benchmarks, the values aren't cryptographically random.
+func RandomMuHash(seed int64) *muhash.MuHash {
+	r := rand.New(rand.NewSource(seed))
+	var raw [2 * muhash.SerializedMuHashSize]byte
+	r.Read(raw[:])
+	mu := muhash.DeserializeRaw(&raw)
+	mu.Normalize()
+	return mu
+}
+
+// SetFieldBackend selects the implementation of the field multiplication, so that tests can force the portable
+// Go implementation ("go") on a machine that has an accelerated one ("amd64" on amd64, unless built with the purego tag),
+// e.g. to run a differential test suite against both. It panics if the backend isn't available in this build.
+// The switch is atomic and both implementations give the same results, so it's safe to call while other
+// goroutines use muhash, but it affects every MuHash in the process.
+func SetFieldBackend(name string) {
+	if !fieldbackend.Set(name) {
+		panic(errors.Errorf("the %q field backend isn't available, the default backend is %q", name,
+			fieldbackend.Default))
+	}
+}
+
+var (
+	testVectorsLock       sync.RWMutex
+	registeredTestVectors = make(map[string]muhash.Hash)
+)
+
+// RegisterTestVector registers the finalized hash of a set containing the elements under the name, so that tests in
+// other packages can share well known commitments (e.g. "genesis") with TestVector instead of copying their elements.
+// It panics if a test vector with the same name is already registered.
+func RegisterTestVector(name string, elements [][]byte) {
+	hash := muhash.HashSet(elements)
+	testVectorsLock.Lock()
+	defer testVectorsLock.Unlock()
+	if _, ok := registeredTestVectors[name]; ok {
+		panic(errors.Errorf("a test vector named %q is already registered", name))
+	}
+	registeredTestVectors[name] = hash
+}
+
+// TestVector returns the finalized hash of the test vector registered under the name with RegisterTestVector.
+// It panics if no test vector with that name is registered.
+func TestVector(name string) muhash.Hash {
+	testVectorsLock.RLock()
+	defer testVectorsLock.RUnlock()
+	hash, ok := registeredTestVectors[name]
+	if !ok {
+		panic(errors.Errorf("no test vector named %q is registered", name))
+	}
+	return hash
+}
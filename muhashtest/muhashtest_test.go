@@ -0,0 +1,87 @@
+package muhashtest
+
+import (
+	"testing"
+
+	"github.com/kaspanet/go-muhash"
+	"github.com/kaspanet/go-muhash/internal/fieldbackend"
+)
+
+func shouldPanic(t *testing.T, name string, f func()) {
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("Expected %s to panic", name)
+		}
+	}()
+	f()
+}
+
+func TestMaxMuHash(t *testing.T) {
+	t.Parallel()
+	max := MaxMuHash()
+	for i, b := range max.SerializeRaw() {
+		if b != 0xff {
+			t.Fatalf("Expected all the bits to be set, found %x in byte #%d", b, i)
+		}
+	}
+	// 2^3072-1 is primeDiff-1 modulo the prime, so the set is equivalent to an empty one.
+	if !max.IsEmpty() {
+		t.Fatalf("Expected %s == %s", max.Finalize(), muhash.EmptyMuHashHash)
+	}
+	// Every call returns an independent set.
+	max.Add([]byte{1})
+	if !MaxMuHash().IsEmpty() {
+		t.Fatalf("Expected MaxMuHash to return a new set every time")
+	}
+}
+
+func TestRandomMuHash(t *testing.T) {
+	t.Parallel()
+	// Pinned so that the fixtures stay the same across platforms and releases.
+	expected := "02bed72fb063c7200400b36ef26a271223f74c7435e83083002d53aceae9d301"
+	random := RandomMuHash(1)
+	if random.Finalize().String() != expected {
+		t.Fatalf("Expected %s == %s", random.Finalize(), expected)
+	}
+	if !random.DenominatorIsOne() {
+		t.Fatalf("Expected RandomMuHash to be normalized")
+	}
+	if _, err := muhash.DeserializeMuHash(random.Serialize()); err != nil {
+		t.Fatalf("Expected RandomMuHash to be a valid set: %v", err)
+	}
+	if RandomMuHash(1).Finalize() != random.Finalize() || RandomMuHash(2).Finalize() == random.Finalize() {
+		t.Fatalf("Expected RandomMuHash to depend only on the seed")
+	}
+}
+
+func TestSetFieldBackend(t *testing.T) {
+	defer SetFieldBackend(fieldbackend.Default)
+	set := muhash.NewMuHash()
+	set.Add([]byte{1})
+	expected := set.Finalize()
+	for _, backend := range []string{fieldbackend.Generic, fieldbackend.Default} {
+		SetFieldBackend(backend)
+		set := muhash.NewMuHash()
+		set.Add([]byte{1})
+		if set.Finalize() != expected {
+			t.Fatalf("Backend %s: Expected %s == %s", backend, set.Finalize(), expected)
+		}
+	}
+	shouldPanic(t, "an unknown backend", func() { SetFieldBackend("unknown") })
+}
+
+func TestRegisterTestVector(t *testing.T) {
+	t.Parallel()
+	elements := [][]byte{{1}, {2}, {3}}
+	set := muhash.NewMuHash()
+	for _, element := range elements {
+		set.Add(element)
+	}
+	RegisterTestVector("TestRegisterTestVector", elements)
+	if hash := TestVector("TestRegisterTestVector"); hash != set.Finalize() {
+		t.Fatalf("Expected %s == %s", hash, set.Finalize())
+	}
+
+	shouldPanic(t, "registering a name twice", func() { RegisterTestVector("TestRegisterTestVector", nil) })
+	shouldPanic(t, "an unregistered name", func() { TestVector("TestRegisterTestVector unregistered") })
+}
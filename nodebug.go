@@ -0,0 +1,8 @@
+//go:build !muhash_debug
+// +build !muhash_debug
+
+package muhash
+
+// checkReduced verifies that the number is fully reduced in builds with the muhash_debug tag,
+// and is compiled out otherwise.
+func checkReduced(*uint3072) {}
@@ -1,27 +1,24 @@
+//go:build muhash_cgo
+// +build muhash_cgo
+
 package muhash
 
 // #include "muhash.h"
 import "C"
 import (
 	"math/big"
-	"math/bits"
 	"unsafe"
 )
 
-type word = C.limb_t
+type limb = C.limb_t
 
-const (
-	wordSizeInBytes = int(unsafe.Sizeof(word(0)))
-	wordSize        = wordSizeInBytes * 8
-	elementWordSize = elementByteSize / wordSizeInBytes
-	maxLimb         = ^word(0)
-)
+const maxLimb = ^limb(0)
 
 func init() {
 	// Some sanity asserts
 	assert(C.LIMBS == elementWordSize)
-	assert(bits.UintSize == unsafe.Sizeof(word(0))*8)
 	assert(unsafe.Sizeof(num3072{}.limbs) == elementByteSize)
+	assert(unsafe.Sizeof(num3072{}.limbs) == unsafe.Sizeof(uint3072{}))
 
 	assert(unsafe.Sizeof(uint(0)) == unsafe.Sizeof(num3072{}.limbs[0]))
 	assert(unsafe.Alignof(uint(0)) == unsafe.Alignof(num3072{}.limbs[0]))
@@ -34,19 +31,32 @@ func init() {
 }
 
 func oneNum3072() num3072 {
-	return num3072{limbs: [C.LIMBS]word{1}}
+	return num3072{limbs: [C.LIMBS]limb{1}}
 }
 
+// num3072 is a cgo binding for the C implementation of the multiplicative group.
+// It isn't used by MuHash, it's only built with `-tags=muhash_cgo` to cross-check uint3072 in tests.
 type num3072 C.Num3072
 
+var (
+	_ field3072               = (*num3072)(nil)
+	_ func(lhs, rhs *num3072) = (*num3072).Mul
+	_ func(lhs, rhs *num3072) = (*num3072).Divide
+)
+
 func (lhs *num3072) SetToOne() {
-	*lhs = num3072{limbs: [C.LIMBS]word{1}}
+	*lhs = num3072{limbs: [C.LIMBS]limb{1}}
 }
 
 func (lhs *num3072) Mul(rhs *num3072) {
 	C.Num3072_Multiply((*C.Num3072)(lhs), (*C.Num3072)(rhs))
 }
 
+func (lhs *num3072) Square() {
+	// Num3072_Multiply computes the product into a temporary before writing it, so lhs can alias rhs.
+	lhs.Mul(lhs)
+}
+
 func (lhs *num3072) Divide(rhs *num3072) {
 	if lhs.IsOverflow() {
 		lhs.FullReduce()
@@ -75,13 +85,15 @@ func (lhs *num3072) FullReduce() {
 }
 
 func (lhs *num3072) GetInverse() *num3072 {
-	if lhs.IsOverflow() {
-		lhs.FullReduce()
-	}
 	inv := *lhs
+	if inv.IsOverflow() {
+		inv.FullReduce()
+	}
 	words := (*[elementWordSize]big.Word)(unsafe.Pointer(&inv.limbs))
 	var bigInt big.Int
 	bigInt.SetBits(words[:])
+	// num3072 is only used in tests, so it's fine for it to use big.Int.
+	prime := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), elementBitSize), big.NewInt(primeDiff))
 	bigInt.ModInverse(&bigInt, prime)
 	for i := len(bigInt.Bits()); i < len(inv.limbs); i++ {
 		inv.limbs[i] = 0
@@ -1,3 +1,6 @@
+//go:build muhash_cgo
+// +build muhash_cgo
+
 package muhash
 
 import (
@@ -5,6 +8,7 @@ import (
 	"runtime"
 	"sync"
 	"testing"
+	"unsafe"
 )
 
 func TestNum3072_GetInverse(t *testing.T) {
@@ -13,7 +17,7 @@ func TestNum3072_GetInverse(t *testing.T) {
 	var element num3072
 	for i := 0; i < 5; i++ {
 		for i := range element.limbs {
-			element.limbs[i] = word(r.Uint64())
+			element.limbs[i] = limb(r.Uint64())
 		}
 		inv := element.GetInverse()
 		again := inv.GetInverse()
@@ -24,7 +28,7 @@ func TestNum3072_GetInverse(t *testing.T) {
 	}
 }
 
-func num3072equalToWord(a *num3072, b word) bool {
+func num3072equalToWord(a *num3072, b limb) bool {
 	if a.limbs[0] != b {
 		return false
 	}
@@ -65,10 +69,10 @@ func TestNum3072_DivOverflow(t *testing.T) {
 	}
 	regularOne := oneNum3072()
 	var wg sync.WaitGroup
-	step := word(primeDiff / runtime.NumCPU())
-	for c := word(0); c < word(runtime.NumCPU()); c++ {
+	step := limb(primeDiff / runtime.NumCPU())
+	for c := limb(0); c < limb(runtime.NumCPU()); c++ {
 		wg.Add(1)
-		go func(c word) {
+		go func(c limb) {
 			defer wg.Done()
 			start := c * step
 			end := start + step
@@ -76,7 +80,7 @@ func TestNum3072_DivOverflow(t *testing.T) {
 				end = primeDiff
 			}
 			for i := end; i > start; i-- {
-				expected := word(primeDiff - i)
+				expected := limb(primeDiff - i)
 				overflown := max
 				overflown.limbs[0] = maxLimb - i + 1
 				overflownCopy := overflown
@@ -111,7 +115,7 @@ func TestNum3072_MulMax(t *testing.T) {
 	t.Parallel()
 	var max num3072
 	for i := range max.limbs {
-		max.limbs[i] = word(maxUint)
+		max.limbs[i] = maxLimb
 	}
 	max.limbs[0] -= primeDiff
 	copyMax := max
@@ -128,7 +132,7 @@ func TestNum3072MulDiv(t *testing.T) {
 	start := oneNum3072()
 	for i := 0; i < loopsN; i++ {
 		for n := range list[i].limbs {
-			list[i].limbs[n] = word(r.Uint64())
+			list[i].limbs[n] = limb(r.Uint64())
 		}
 		start.Mul(&list[i])
 	}
@@ -146,9 +150,107 @@ func TestNum3072MulDiv(t *testing.T) {
 
 // This specifically tests the zeroing loop at the end of num3072.GetInverse.
 func TestNum3072_GetInverse_EdgeCase(t *testing.T) {
-	orig := num3072{limbs: [limbs]word{7122228832992001076, 984226626229791276, 7630161757215403889, 6284986028532537849, 8045609952094061025, 11960578682873843289, 13746438324198032094, 13918942278011779234, 17733507388171786846, 10563242470999117317, 17037155475664456442, 17937456968131788544, 12599342294785769540, 13386260146859547870, 2817582499516127913, 652557987984108933, 9669847560665129471, 17711760030167214508, 5376140856964249866, 18051557786492143716, 2482926987284881227, 8605482545261324676, 7878786448874819977, 1266815984192471985, 2678516262590404672, 14004775981272003760, 10357003870690124643, 2730710396948079405, 4635754375072562978, 13656184258619915136, 803512205739688286, 11844116904145642840, 5760653310472302601, 15069027324939031326, 14913021043324743434, 17567013163360751106, 6302557725767759643, 17458497366820989801, 3410551217786514778, 14182717432968305815, 12471950523812677269, 2294197765573979691, 3220941588656114052, 605606616684921311, 1440136155000853957, 16361481774333736133, 11385241783616172231, 13968855456762740410}}
+	orig := num3072{limbs: [limbs]limb{7122228832992001076, 984226626229791276, 7630161757215403889, 6284986028532537849, 8045609952094061025, 11960578682873843289, 13746438324198032094, 13918942278011779234, 17733507388171786846, 10563242470999117317, 17037155475664456442, 17937456968131788544, 12599342294785769540, 13386260146859547870, 2817582499516127913, 652557987984108933, 9669847560665129471, 17711760030167214508, 5376140856964249866, 18051557786492143716, 2482926987284881227, 8605482545261324676, 7878786448874819977, 1266815984192471985, 2678516262590404672, 14004775981272003760, 10357003870690124643, 2730710396948079405, 4635754375072562978, 13656184258619915136, 803512205739688286, 11844116904145642840, 5760653310472302601, 15069027324939031326, 14913021043324743434, 17567013163360751106, 6302557725767759643, 17458497366820989801, 3410551217786514778, 14182717432968305815, 12471950523812677269, 2294197765573979691, 3220941588656114052, 605606616684921311, 1440136155000853957, 16361481774333736133, 11385241783616172231, 13968855456762740410}}
 	inverse := orig.GetInverse()
 	if *inverse.GetInverse() != orig {
 		t.Fatalf("Double inverting resulted in different varaible than the original: %v", orig)
 	}
 }
+
+func TestNum3072_DivideDoesNotModifyDivisor(t *testing.T) {
+	t.Parallel()
+	var divisor num3072
+	for i := range divisor.limbs {
+		divisor.limbs[i] = maxLimb
+	}
+	divisor.limbs[0] -= primeDiff / 2
+	if !divisor.IsOverflow() {
+		t.Fatalf("Expected %v to be overflown", divisor)
+	}
+	divisorCopy := divisor
+	lhs := oneNum3072()
+	lhs.Divide(&divisor)
+	if divisor != divisorCopy {
+		t.Fatalf("Divide modified its divisor: %v != %v", divisor, divisorCopy)
+	}
+	lhs.Mul(&divisor)
+	if !num3072equalToWord(&lhs, 1) {
+		t.Fatalf("Expected %v to be 1", lhs)
+	}
+}
+
+func TestNum3072_MatchesUint3072(t *testing.T) {
+	t.Parallel()
+	r := rand.New(rand.NewSource(0))
+	numStart := oneNum3072()
+	uintStart := one()
+	for i := 0; i < loopsN; i++ {
+		var num num3072
+		var uin uint3072
+		for j := range num.limbs {
+			num.limbs[j] = limb(r.Uint64())
+			uin[j] = uint(num.limbs[j])
+		}
+		switch i % 3 {
+		case 0:
+			numStart.Mul(&num)
+			uintStart.Mul(&uin)
+		case 1:
+			numStart.Divide(&num)
+			uintStart.Divide(&uin)
+		case 2:
+			numStart.Mul(&num)
+			uintStart.Mul(&uin)
+			numStart.Square()
+			uintStart.Square()
+		}
+		if *(*uint3072)(unsafe.Pointer(&numStart.limbs)) != uintStart {
+			t.Fatalf("Iteration #%d: Expected %v == %v", i, numStart, uintStart)
+		}
+	}
+}
+
+// TestNum3072_MatchesUint3072Sets adds and removes the same elements from sets on both backends,
+// and checks that their limbs are identical after every step.
+func TestNum3072_MatchesUint3072Sets(t *testing.T) {
+	t.Parallel()
+	r := rand.New(rand.NewSource(0))
+	data := make([][]byte, 0, len(testVectors)+1000)
+	for _, test := range testVectors {
+		data = append(data, test.dataElement)
+	}
+	for i := 0; i < 1000; i++ {
+		element := make([]byte, r.Intn(200))
+		r.Read(element)
+		data = append(data, element)
+	}
+
+	for sequence := 0; sequence < 20; sequence++ {
+		uintNumerator, uintDenominator := one(), one()
+		numNumerator, numDenominator := oneNum3072(), oneNum3072()
+		for step := 0; step < 100; step++ {
+			var element uint3072
+			dataToElement(data[r.Intn(len(data))], &element)
+			numElement := (*num3072)(unsafe.Pointer(&element))
+			switch r.Intn(3) {
+			case 0:
+				uintNumerator.Mul(&element)
+				numNumerator.Mul(numElement)
+			case 1:
+				uintDenominator.Mul(&element)
+				numDenominator.Mul(numElement)
+			case 2:
+				// Normalize, like MuHash does before serializing.
+				uintNumerator.Divide(&uintDenominator)
+				uintDenominator.SetToOne()
+				numNumerator.Divide(&numDenominator)
+				numDenominator.SetToOne()
+			}
+			if *(*uint3072)(unsafe.Pointer(&numNumerator.limbs)) != uintNumerator ||
+				*(*uint3072)(unsafe.Pointer(&numDenominator.limbs)) != uintDenominator {
+				t.Fatalf("Sequence #%d step #%d: the backends diverged, %v/%v != %v/%v",
+					sequence, step, numNumerator, numDenominator, uintNumerator, uintDenominator)
+			}
+		}
+	}
+}
@@ -0,0 +1,9 @@
+//go:build race
+// +build race
+
+package muhash
+
+// raceEnabled is true when the tests are built with the race detector. It makes sync.Pool randomly drop items,
+// so the tests can't assert that code using a pool (the element scratch, or the muhash_debug big.Ints)
+// doesn't allocate.
+const raceEnabled = true
@@ -0,0 +1,150 @@
+package muhash
+
+import (
+	"golang.org/x/crypto/blake2b"
+	"golang.org/x/crypto/chacha20"
+	"math/big"
+	"math/rand"
+	"testing"
+)
+
+// prime is 2^3072 - 1103717, the modulus, for the big.Int reference arithmetic in tests.
+var prime = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), elementBitSize), big.NewInt(primeDiff))
+
+// leBytesToBigInt converts little endian bytes into a big.Int.
+func leBytesToBigInt(data []byte) *big.Int {
+	reversed := make([]byte, len(data))
+	for i := range data {
+		reversed[len(data)-1-i] = data[i]
+	}
+	return new(big.Int).SetBytes(reversed)
+}
+
+// bigIntToLEBytes converts a big.Int smaller than 2^3072 into elementByteSize little endian bytes.
+func bigIntToLEBytes(n *big.Int) []byte {
+	data := n.FillBytes(make([]byte, elementByteSize))
+	for i := 0; i < len(data)/2; i++ {
+		data[i], data[len(data)-1-i] = data[len(data)-1-i], data[i]
+	}
+	return data
+}
+
+// bigMuHash is a reference MuHash implemented with math/big, for differential testing of the limb arithmetic.
+// It derives its elements and finalizes independently of MuHash.
+type bigMuHash struct {
+	numerator   *big.Int
+	denominator *big.Int
+}
+
+func newBigMuHash() *bigMuHash {
+	return &bigMuHash{numerator: big.NewInt(1), denominator: big.NewInt(1)}
+}
+
+func bigElement(data []byte) *big.Int {
+	hasher, err := blake2b.New256([]byte("MuHashElement"))
+	if err != nil {
+		panic(err)
+	}
+	hasher.Write(data)
+	stream, err := chacha20.NewUnauthenticatedCipher(hasher.Sum(nil), make([]byte, chacha20.NonceSize))
+	if err != nil {
+		panic(err)
+	}
+	element := make([]byte, elementByteSize)
+	stream.XORKeyStream(element, element)
+	return leBytesToBigInt(element)
+}
+
+func (mu *bigMuHash) Add(data []byte) {
+	mu.numerator.Mul(mu.numerator, bigElement(data))
+	mu.numerator.Mod(mu.numerator, prime)
+}
+
+func (mu *bigMuHash) Remove(data []byte) {
+	mu.denominator.Mul(mu.denominator, bigElement(data))
+	mu.denominator.Mod(mu.denominator, prime)
+}
+
+func (mu *bigMuHash) Combine(other *bigMuHash) {
+	mu.numerator.Mul(mu.numerator, other.numerator)
+	mu.numerator.Mod(mu.numerator, prime)
+	mu.denominator.Mul(mu.denominator, other.denominator)
+	mu.denominator.Mod(mu.denominator, prime)
+}
+
+func (mu *bigMuHash) Finalize() Hash {
+	normalized := new(big.Int).ModInverse(mu.denominator, prime)
+	normalized.Mul(normalized, mu.numerator)
+	normalized.Mod(normalized, prime)
+	hasher, err := blake2b.New256([]byte("MuHashFinalize"))
+	if err != nil {
+		panic(err)
+	}
+	hasher.Write(bigIntToLEBytes(normalized))
+	var res Hash
+	hasher.Sum(res[:0])
+	return res
+}
+
+// TestVectors_MatchBigMuHash recomputes the test vectors with bigMuHash, which doesn't depend on the word size,
+// so the vectors are known to be correct independently of the limb layout they were generated with.
+// The limb arithmetic itself is checked against the vectors on 32 bit by running the tests with GOARCH=386.
+func TestVectors_MatchBigMuHash(t *testing.T) {
+	t.Parallel()
+	cumulative := newBigMuHash()
+	for i, test := range testVectors {
+		single := newBigMuHash()
+		single.Add(test.dataElement)
+		if hash := single.Finalize(); hash != test.multisetHash {
+			t.Fatalf("Test #%d: Expected %s == %s", i, hash, test.multisetHash)
+		}
+		cumulative.Add(test.dataElement)
+		if hash := cumulative.Finalize(); hash != test.cumulativeHash {
+			t.Fatalf("Test #%d: Expected %s == %s", i, hash, test.cumulativeHash)
+		}
+	}
+}
+
+func TestMuHash_MatchesBigMuHash(t *testing.T) {
+	t.Parallel()
+	r := rand.New(rand.NewSource(3))
+	const setsN = 4
+	sets := make([]*MuHash, setsN)
+	bigSets := make([]*bigMuHash, setsN)
+	for i := range sets {
+		sets[i] = NewMuHash()
+		bigSets[i] = newBigMuHash()
+	}
+	for i := 0; i < 300; i++ {
+		index := r.Intn(setsN)
+		switch r.Intn(3) {
+		case 0, 1:
+			data := make([]byte, r.Intn(200))
+			r.Read(data)
+			if r.Intn(2) == 0 {
+				sets[index].Add(data)
+				bigSets[index].Add(data)
+			} else {
+				sets[index].Remove(data)
+				bigSets[index].Remove(data)
+			}
+		case 2:
+			other := r.Intn(setsN)
+			sets[index].Combine(sets[other])
+			bigSets[index].Combine(bigSets[other])
+		}
+		if i%10 == 0 {
+			if sets[index].Finalize() != bigSets[index].Finalize() {
+				t.Fatalf("Step #%d: Expected %s == %s", i, sets[index].Finalize(), bigSets[index].Finalize())
+			}
+		}
+	}
+	for i := range sets {
+		if sets[i].Finalize() != bigSets[i].Finalize() {
+			t.Fatalf("Set #%d: Expected %s == %s", i, sets[i].Finalize(), bigSets[i].Finalize())
+		}
+	}
+	if newBigMuHash().Finalize() != EmptyMuHashHash {
+		t.Fatalf("Expected %s == %s", newBigMuHash().Finalize(), EmptyMuHashHash)
+	}
+}
@@ -0,0 +1,384 @@
+package muhash
+
+import "math/bits"
+
+// This is a variable time modular inversion using the "safegcd" algorithm by Bernstein and Yang,
+// ported from libsecp256k1's secp256k1_modinv64_var and extended to 3072 bit numbers.
+// See "Fast constant-time gcd computation and modular inversion" (Bernstein, Yang, 2019),
+// and the explanation in libsecp256k1's doc/safegcd_implementation.md.
+// Unlike big.Int's ModInverse it works on fixed size arrays, so it doesn't allocate.
+
+const (
+	// signed62Limbs is the number of 62 bit limbs needed to hold numbers in the range (-2*p, p).
+	signed62Limbs = (elementByteSize*8 + 2 + 61) / 62
+	mask62        = ^uint64(0) >> 2
+)
+
+// signed62 is a signed number in base 2^62. All limbs but the top one are in [0, 2^62),
+// and the top one is signed.
+type signed62 [signed62Limbs]int64
+
+// trans2x2 is the transition matrix of 62 divsteps, multiplied by 2^62.
+type trans2x2 struct {
+	u, v, q, r int64
+}
+
+var (
+	// primeUint3072 is the prime as uint3072.
+	primeUint3072 = primeAsUint3072()
+	// modulus62 is the prime as signed62.
+	modulus62 = toSigned62(&primeUint3072)
+	// modulusInv62 is the inverse of the prime modulo 2^62.
+	modulusInv62 = primeInv62()
+)
+
+func primeAsUint3072() uint3072 {
+	var prime uint3072
+	for i := range prime {
+		prime[i] = maxUint
+	}
+	prime[0] -= primeDiff - 1
+	return prime
+}
+
+func primeInv62() uint64 {
+	// The prime is -primeDiff modulo 2^62, so its inverse is -primeDiff^-1.
+	// Each Newton iteration doubles the number of correct bits, starting from 3.
+	inv := uint64(primeDiff)
+	for i := 0; i < 5; i++ {
+		inv *= 2 - primeDiff*inv
+	}
+	return -inv & mask62
+}
+
+// toUint64s converts the number into 64 bit little endian words, independent of the word size.
+func toUint64s(x *uint3072) [elementByteSize / 8]uint64 {
+	var out [elementByteSize / 8]uint64
+	switch wordSize {
+	case 64:
+		for i := range out {
+			out[i] = uint64(x[i])
+		}
+	case 32:
+		for i := range out {
+			out[i] = uint64(x[2*i]) | uint64(x[2*i+1])<<32
+		}
+	default:
+		panic("Only 32/64 bits machines are supported")
+	}
+	return out
+}
+
+// fromUint64s converts 64 bit little endian words back into a uint3072.
+func fromUint64s(words *[elementByteSize / 8]uint64) uint3072 {
+	var out uint3072
+	switch wordSize {
+	case 64:
+		for i := range words {
+			out[i] = uint(words[i])
+		}
+	case 32:
+		for i := range words {
+			out[2*i] = uint(uint32(words[i]))
+			out[2*i+1] = uint(words[i] >> 32)
+		}
+	default:
+		panic("Only 32/64 bits machines are supported")
+	}
+	return out
+}
+
+func toSigned62(x *uint3072) signed62 {
+	words := toUint64s(x)
+	var out signed62
+	for i := range out {
+		word, shift := 62*i/64, uint(62*i%64)
+		limb := words[word] >> shift
+		if word+1 < len(words) {
+			limb |= words[word+1] << (64 - shift)
+		}
+		out[i] = int64(limb & mask62)
+	}
+	return out
+}
+
+// fromSigned62 converts a number in [0, p) back into a uint3072.
+func fromSigned62(x *signed62) uint3072 {
+	var words [elementByteSize / 8]uint64
+	for i, limb := range x {
+		word, shift := 62*i/64, uint(62*i%64)
+		if word >= len(words) {
+			break
+		}
+		words[word] |= uint64(limb) << shift
+		if word+1 < len(words) {
+			words[word+1] |= uint64(limb) >> (64 - shift)
+		}
+	}
+	return fromUint64s(&words)
+}
+
+// int128 is a signed 128 bit integer, used to accumulate products of int64s.
+type int128 struct {
+	hi int64
+	lo uint64
+}
+
+// mulAdd returns n+a*b.
+func (n int128) mulAdd(a, b int64) int128 {
+	hi, lo := bits.Mul64(uint64(a), uint64(b))
+	// Correct the unsigned product of the two's complement values into a signed one,
+	// without branching on the signs which are unpredictable.
+	signedHi := int64(hi) - (b & (a >> 63)) - (a & (b >> 63))
+	var carry uint64
+	n.lo, carry = bits.Add64(n.lo, lo, 0)
+	n.hi += signedHi + int64(carry)
+	return n
+}
+
+// mulAddLimb returns n+a*limb, where limb is known to be non-negative, like all but the top limb of a signed62.
+func (n int128) mulAddLimb(a, limb int64) int128 {
+	hi, lo := bits.Mul64(uint64(a), uint64(limb))
+	signedHi := int64(hi) - (limb & (a >> 63))
+	var carry uint64
+	n.lo, carry = bits.Add64(n.lo, lo, 0)
+	n.hi += signedHi + int64(carry)
+	return n
+}
+
+// shift62 returns n shifted right by 62 bits, rounding towards negative infinity.
+func (n int128) shift62() int128 {
+	return int128{hi: n.hi >> 62, lo: n.lo>>62 | uint64(n.hi)<<2}
+}
+
+// divsteps62Var performs 62 divsteps on the bottom bits of f and g, and returns the new eta
+// and the transition matrix to apply them to the full numbers. eta is -delta of the paper.
+func divsteps62Var(eta int64, f0, g0 uint64, t *trans2x2) int64 {
+	// [u,v;q,r] is the transition matrix, multiplied by 2^(the number of divsteps done).
+	u, v, q, r := uint64(1), uint64(0), uint64(0), uint64(1)
+	f, g := f0, g0
+	i := 62
+	for {
+		// Use a sentinel bit to count zeros only up to i.
+		zeros := bits.TrailingZeros64(g | (^uint64(0) << uint(i)))
+		// Perform zeros divsteps at once, they all just divide g by two.
+		g >>= uint(zeros)
+		u <<= uint(zeros)
+		v <<= uint(zeros)
+		eta -= int64(zeros)
+		i -= zeros
+		if i == 0 {
+			break
+		}
+		// g is odd now.
+		var w, m uint64
+		limit := i
+		if eta+1 < int64(i) {
+			limit = int(eta + 1)
+		}
+		if eta < 0 {
+			// Negate eta and replace f,g with g,-f.
+			eta = -eta
+			f, g = g, -f
+			u, q = q, -u
+			v, r = r, -v
+			limit = i
+			if eta+1 < int64(i) {
+				limit = int(eta + 1)
+			}
+			// Cancel out up to 6 bits of g, but not more than limit.
+			m = (^uint64(0) >> uint(64-limit)) & 63
+			w = (f * g * (f*f - 2)) & m
+		} else {
+			// Cancel out up to 4 bits of g, but not more than limit.
+			m = (^uint64(0) >> uint(64-limit)) & 15
+			w = f + (((f + 1) & 4) << 1)
+			w = (-w * g) & m
+		}
+		g += f * w
+		q += u * w
+		r += v * w
+	}
+	t.u, t.v, t.q, t.r = int64(u), int64(v), int64(q), int64(r)
+	return eta
+}
+
+// updateDE sets [d,e] to t*[d,e]/2^62 modulo the prime, keeping them in the range (-2*p, p).
+func updateDE(d, e *signed62, t *trans2x2) {
+	u, v, q, r := t.u, t.v, t.q, t.r
+	// [md,me] start as zero, plus [u,q] if d is negative, plus [v,r] if e is negative.
+	sd := d[signed62Limbs-1] >> 63
+	se := e[signed62Limbs-1] >> 63
+	md := (u & sd) + (v & se)
+	me := (q & sd) + (r & se)
+
+	var cd, ce int128
+	cd = cd.mulAddLimb(u, d[0]).mulAddLimb(v, e[0])
+	ce = ce.mulAddLimb(q, d[0]).mulAddLimb(r, e[0])
+	// Correct md,me so that t*[d,e]+modulus*[md,me] has 62 zero bottom bits.
+	md -= int64((modulusInv62*cd.lo + uint64(md)) & mask62)
+	me -= int64((modulusInv62*ce.lo + uint64(me)) & mask62)
+	// The prime is 2^3072 - primeDiff, so instead of multiplying [md,me] by all of its limbs,
+	// -primeDiff is added at the bottom limb and 2^3072 at the top one.
+	cd = cd.mulAdd(-primeDiff, md).shift62()
+	ce = ce.mulAdd(-primeDiff, me).shift62()
+	for i := 1; i < signed62Limbs-1; i++ {
+		cd = cd.mulAddLimb(u, d[i]).mulAddLimb(v, e[i])
+		ce = ce.mulAddLimb(q, d[i]).mulAddLimb(r, e[i])
+		d[i-1] = int64(cd.lo & mask62)
+		e[i-1] = int64(ce.lo & mask62)
+		cd = cd.shift62()
+		ce = ce.shift62()
+	}
+	const top = signed62Limbs - 1
+	const topModulus = 1 << (elementBitSize - 62*top)
+	cd = cd.mulAdd(u, d[top]).mulAdd(v, e[top]).mulAdd(topModulus, md)
+	ce = ce.mulAdd(q, d[top]).mulAdd(r, e[top]).mulAdd(topModulus, me)
+	d[top-1] = int64(cd.lo & mask62)
+	e[top-1] = int64(ce.lo & mask62)
+	cd = cd.shift62()
+	ce = ce.shift62()
+	d[top] = int64(cd.lo)
+	e[top] = int64(ce.lo)
+}
+
+// updateFGVar sets [f,g] to t*[f,g]/2^62, where only the bottom length limbs of f and g are used.
+func updateFGVar(length int, f, g *signed62, t *trans2x2) {
+	u, v, q, r := t.u, t.v, t.q, t.r
+	top := length - 1
+	var cf, cg int128
+	// The bottom limb may be the top one too, in which case it can be negative.
+	cf = cf.mulAdd(u, f[0]).mulAdd(v, g[0])
+	cg = cg.mulAdd(q, f[0]).mulAdd(r, g[0])
+	// The bottom 62 bits are zero.
+	cf = cf.shift62()
+	cg = cg.shift62()
+	if top == 0 {
+		f[0] = int64(cf.lo)
+		g[0] = int64(cg.lo)
+		return
+	}
+	for i := 1; i < top; i++ {
+		cf = cf.mulAddLimb(u, f[i]).mulAddLimb(v, g[i])
+		cg = cg.mulAddLimb(q, f[i]).mulAddLimb(r, g[i])
+		f[i-1] = int64(cf.lo & mask62)
+		g[i-1] = int64(cg.lo & mask62)
+		cf = cf.shift62()
+		cg = cg.shift62()
+	}
+	cf = cf.mulAdd(u, f[top]).mulAdd(v, g[top])
+	cg = cg.mulAdd(q, f[top]).mulAdd(r, g[top])
+	f[top-1] = int64(cf.lo & mask62)
+	g[top-1] = int64(cg.lo & mask62)
+	cf = cf.shift62()
+	cg = cg.shift62()
+	f[top] = int64(cf.lo)
+	g[top] = int64(cg.lo)
+}
+
+// normalize62 takes r in the range (-2*p, p), negates it if sign is negative,
+// and returns it in the range [0, p).
+func normalize62(r *signed62, sign int64) {
+	// Add the prime if r is negative, and then negate if requested.
+	condAdd := r[signed62Limbs-1] >> 63
+	condNegate := sign >> 63
+	for i := range r {
+		r[i] += modulus62[i] & condAdd
+		r[i] = (r[i] ^ condNegate) - condNegate
+	}
+	r.propagateCarries()
+	// Add the prime again if the result is still negative, bringing r to [0, p).
+	condAdd = r[signed62Limbs-1] >> 63
+	for i := range r {
+		r[i] += modulus62[i] & condAdd
+	}
+	r.propagateCarries()
+}
+
+// propagateCarries brings all the limbs but the top one back to the range [0, 2^62).
+func (r *signed62) propagateCarries() {
+	for i := 0; i < signed62Limbs-1; i++ {
+		r[i+1] += r[i] >> 62
+		r[i] &= int64(mask62)
+	}
+}
+
+// safegcdInverse returns the inverse of x modulo the prime, x must be fully reduced.
+// Zero doesn't have an inverse, so zero is returned for it.
+func safegcdInverse(x *uint3072) uint3072 {
+	var d, e signed62
+	e[0] = 1
+	f := modulus62
+	g := toSigned62(x)
+	eta := int64(-1)
+	length := signed62Limbs
+	for {
+		var t trans2x2
+		eta = divsteps62Var(eta, uint64(f[0]), uint64(g[0]), &t)
+		updateDE(&d, &e, &t)
+		updateFGVar(length, &f, &g, &t)
+		// If the bottom limb of g is zero, there is a chance g is zero, in which case we're done.
+		if g[0] == 0 {
+			var cond int64
+			for i := 1; i < length; i++ {
+				cond |= g[i]
+			}
+			if cond == 0 {
+				break
+			}
+		}
+		// If the top limbs of both f and g are 0 or -1, fold them into the limb below and shorten them.
+		fn, gn := f[length-1], g[length-1]
+		cond := int64(length-2) >> 63
+		cond |= fn ^ (fn >> 63)
+		cond |= gn ^ (gn >> 63)
+		if cond == 0 {
+			f[length-2] |= int64(uint64(fn) << 62)
+			g[length-2] |= int64(uint64(gn) << 62)
+			length--
+		}
+	}
+	// f is now the gcd, ±1, and d is its sign times the inverse.
+	normalize62(&d, f[length-1])
+	return fromSigned62(&d)
+}
+
+// smallInverse returns the inverse of 0 < x < 2^32 modulo the prime.
+// safegcd takes about as long for small numbers as for any other number, while this
+// is a single division of the prime by x and an extended Euclid on single words.
+func smallInverse(x uint32) uint3072 {
+	// Divide the prime by x, p = quotient*x + rem.
+	var quotient uint3072
+	var rem uint
+	for i := len(quotient) - 1; i >= 0; i-- {
+		quotient[i], rem = bits.Div(rem, primeUint3072[i], uint(x))
+	}
+	// For k = -rem^-1 mod x, 1 + k*p is divisible by x, so the inverse is
+	// (1 + k*p)/x = k*quotient + (1 + k*rem)/x, which is less than p so there's no need to reduce it.
+	k := (uint64(x) - wordModInverse(uint64(rem), uint64(x))) % uint64(x)
+	carry := uint((1 + k*uint64(rem)) / uint64(x))
+	var inverse uint3072
+	for i := range quotient {
+		hi, lo := bits.Mul(quotient[i], uint(k))
+		var c uint
+		inverse[i], c = bits.Add(lo, carry, 0)
+		carry = hi + c
+	}
+	return inverse
+}
+
+// wordModInverse returns the inverse of a modulo m using the extended Euclidean algorithm.
+// a and m must be coprime and smaller than 2^32.
+func wordModInverse(a, m uint64) uint64 {
+	t, newT := int64(0), int64(1)
+	r, newR := int64(m), int64(a)
+	for newR != 0 {
+		quotient := r / newR
+		t, newT = newT, t-quotient*newT
+		r, newR = newR, r-quotient*newR
+	}
+	if t < 0 {
+		t += int64(m)
+	}
+	return uint64(t) % m
+}
@@ -0,0 +1,115 @@
+package muhash
+
+import (
+	"math"
+	"math/big"
+	"math/rand"
+	"testing"
+)
+
+func uint3072ToBigInt(num *uint3072) *big.Int {
+	var numBytes [elementByteSize]byte
+	wordsToBytesLE(num, &numBytes)
+	return leBytesToBigInt(numBytes[:])
+}
+
+func bigIntToUint3072(n *big.Int) uint3072 {
+	var numBytes [elementByteSize]byte
+	copy(numBytes[:], bigIntToLEBytes(n))
+	var num uint3072
+	bytesToWordsLE(&numBytes, &num)
+	return num
+}
+
+func TestSafegcdInverse(t *testing.T) {
+	t.Parallel()
+	r := rand.New(rand.NewSource(0))
+	inputs := []*big.Int{
+		big.NewInt(1),
+		big.NewInt(2),
+		big.NewInt(primeDiff),
+		new(big.Int).Sub(prime, big.NewInt(1)),
+		new(big.Int).Sub(prime, big.NewInt(2)),
+		new(big.Int).Rsh(prime, 1),
+		new(big.Int).Lsh(big.NewInt(1), elementBitSize-1),
+	}
+	for i := 0; i < 200; i++ {
+		inputs = append(inputs, new(big.Int).Rand(r, prime))
+	}
+	// Small and sparse numbers exercise the early termination and the shrinking of f and g.
+	for i := 0; i < 50; i++ {
+		inputs = append(inputs, new(big.Int).Lsh(big.NewInt(r.Int63()+1), uint(r.Intn(elementBitSize-64))))
+	}
+	for i, input := range inputs {
+		if input.Sign() == 0 {
+			continue
+		}
+		num := bigIntToUint3072(input)
+		inverse := safegcdInverse(&num)
+		expected := new(big.Int).ModInverse(input, prime)
+		if uint3072ToBigInt(&inverse).Cmp(expected) != 0 {
+			t.Fatalf("Input #%d: Expected the inverse of %x to be %x, got %x",
+				i, input, expected, uint3072ToBigInt(&inverse))
+		}
+	}
+}
+
+func TestSafegcdInverseZero(t *testing.T) {
+	t.Parallel()
+	zero := uint3072{}
+	if inverse := safegcdInverse(&zero); !inverse.IsZero() {
+		t.Fatalf("Expected the inverse of zero to be zero, got %v", inverse)
+	}
+}
+
+func TestSmallInverse(t *testing.T) {
+	t.Parallel()
+	r := rand.New(rand.NewSource(0))
+	inputs := []uint32{math.MaxUint32, math.MaxUint32 - 1, 1 << 31, primeDiff, primeDiff - 1, primeDiff + 1}
+	for i := uint32(1); i < 1000; i++ {
+		inputs = append(inputs, i)
+	}
+	for i := 0; i < 1000; i++ {
+		inputs = append(inputs, r.Uint32()|1)
+	}
+	for _, input := range inputs {
+		inverse := smallInverse(input)
+		expected := new(big.Int).ModInverse(new(big.Int).SetUint64(uint64(input)), prime)
+		if uint3072ToBigInt(&inverse).Cmp(expected) != 0 {
+			t.Fatalf("Expected the inverse of %d to be %x, got %x", input, expected, uint3072ToBigInt(&inverse))
+		}
+		num := uint3072{uint(input)}
+		if modInverse := num.modInverse(); modInverse != inverse {
+			t.Fatalf("Expected modInverse of %d to use the same inverse, got %v", input, modInverse)
+		}
+	}
+}
+
+func TestSigned62RoundTrip(t *testing.T) {
+	t.Parallel()
+	r := rand.New(rand.NewSource(0))
+	for i := 0; i < 100; i++ {
+		num := bigIntToUint3072(new(big.Int).Rand(r, prime))
+		converted := toSigned62(&num)
+		if roundTrip := fromSigned62(&converted); roundTrip != num {
+			t.Fatalf("Expected %v == %v", roundTrip, num)
+		}
+	}
+	prime62 := bigIntToUint3072(prime)
+	if converted := toSigned62(&prime62); converted != modulus62 {
+		t.Fatalf("Expected modulus62 to be the prime, found %v", modulus62)
+	}
+	if inv := new(big.Int).ModInverse(prime, new(big.Int).Lsh(big.NewInt(1), 62)); inv.Uint64() != modulusInv62 {
+		t.Fatalf("Expected modulusInv62 to be %x, found %x", inv.Uint64(), modulusInv62)
+	}
+}
+
+func BenchmarkSafegcdInverse(b *testing.B) {
+	r := rand.New(rand.NewSource(0))
+	num := bigIntToUint3072(new(big.Int).Rand(r, prime))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		safegcdInverse(&num)
+	}
+}
@@ -0,0 +1,68 @@
+package muhash
+
+import "github.com/pkg/errors"
+
+var errNegativeMultiplicity = errors.New("Removing an element that isn't in the set")
+
+// TrackedMuHash is a MuHash that keeps track of the multiplicity of its elements, and refuses to remove an element
+// that isn't in the set instead of letting its multiplicity go negative, e.g. to catch spending an output twice.
+// Its commitment is identical to a MuHash's with the same elements added and removed, tracking is only a guardrail
+// for debugging.
+//
+// The multiplicities are kept in a map keyed by the blake2b hash of each element, which costs roughly 60-100 bytes
+// of memory per distinct element in the set (32 bytes for the hash, 8 for the count plus the map's overhead),
+// e.g. tens of megabytes for a million elements.
+// Use NewMuHashTracked to initialize a TrackedMuHash.
+type TrackedMuHash struct {
+	inner          MuHash
+	multiplicities map[Hash]uint64
+}
+
+// NewMuHashTracked returns an empty initialized tracked set.
+func NewMuHashTracked() *TrackedMuHash {
+	return &TrackedMuHash{inner: *NewMuHash(), multiplicities: make(map[Hash]uint64)}
+}
+
+// Add hashes the data and adds it to the set, increasing its multiplicity.
+func (tracked *TrackedMuHash) Add(data []byte) {
+	hashed := elementHash(data)
+	tracked.multiplicities[hashed]++
+	var element uint3072
+	hashToElement(&hashed, &element)
+	tracked.inner.addElement(&element)
+}
+
+// Remove hashes the data and removes it from the set, decreasing its multiplicity.
+// An error is returned if the data isn't in the set, in which case the set isn't modified.
+func (tracked *TrackedMuHash) Remove(data []byte) error {
+	hashed := elementHash(data)
+	multiplicity, ok := tracked.multiplicities[hashed]
+	if !ok {
+		return errors.Wrapf(errNegativeMultiplicity, "element %s", hashed)
+	}
+	if multiplicity == 1 {
+		delete(tracked.multiplicities, hashed)
+	} else {
+		tracked.multiplicities[hashed] = multiplicity - 1
+	}
+	var element uint3072
+	hashToElement(&hashed, &element)
+	tracked.inner.removeElement(&element)
+	return nil
+}
+
+// Multiplicity returns the number of times the data is in the set.
+func (tracked *TrackedMuHash) Multiplicity(data []byte) uint64 {
+	return tracked.multiplicities[elementHash(data)]
+}
+
+// MuHash returns a copy of the set as a MuHash, e.g. to serialize it or to combine it with other sets.
+func (tracked *TrackedMuHash) MuHash() *MuHash {
+	return tracked.inner.Clone()
+}
+
+// Finalize will return a hash(Blake2b) of the set. It's the same as the finalized hash of a MuHash with
+// the same elements added and removed.
+func (tracked *TrackedMuHash) Finalize() Hash {
+	return tracked.inner.Finalize()
+}
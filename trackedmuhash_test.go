@@ -0,0 +1,45 @@
+package muhash
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestTrackedMuHash(t *testing.T) {
+	t.Parallel()
+	tracked := NewMuHashTracked()
+	expected := NewMuHash()
+	for _, i := range []byte{1, 2, 2, 3} {
+		tracked.Add(elementFromByte(i))
+		expected.Add(elementFromByte(i))
+	}
+	if err := tracked.Remove(elementFromByte(2)); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	expected.Remove(elementFromByte(2))
+	if tracked.Multiplicity(elementFromByte(2)) != 1 || tracked.Multiplicity(elementFromByte(4)) != 0 {
+		t.Fatalf("Expected multiplicities 1 and 0, found %d and %d",
+			tracked.Multiplicity(elementFromByte(2)), tracked.Multiplicity(elementFromByte(4)))
+	}
+	if tracked.Finalize() != expected.Finalize() {
+		t.Fatalf("Expected %s == %s", tracked.Finalize(), expected.Finalize())
+	}
+	if !tracked.MuHash().Serialize().Equal(expected.Serialize()) {
+		t.Fatalf("Expected the MuHash of the tracked set to equal a MuHash of its elements")
+	}
+
+	// Removing an element that isn't in the set, or removing one twice, fails without modifying the set.
+	if err := tracked.Remove(elementFromByte(1)); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	expected.Remove(elementFromByte(1))
+	for _, i := range []byte{1, 4} {
+		err := tracked.Remove(elementFromByte(i))
+		if !errors.Is(err, errNegativeMultiplicity) {
+			t.Fatalf("Expected %s, instead found: %v", errNegativeMultiplicity, err)
+		}
+	}
+	if tracked.Finalize() != expected.Finalize() {
+		t.Fatalf("Expected a failed Remove not to modify the set: %s == %s", tracked.Finalize(), expected.Finalize())
+	}
+}
@@ -0,0 +1,15 @@
+//go:build go1.18
+// +build go1.18
+
+package muhash
+
+// AddValue encodes the value with enc and adds the encoding to the multiset, exactly like `mu.Add(enc(v))`.
+// It keeps the encoder of a record type next to where its values are committed.
+func AddValue[T any](mu *MuHash, v T, enc func(T) []byte) {
+	mu.Add(enc(v))
+}
+
+// RemoveValue encodes the value with enc and removes the encoding from the multiset, exactly like `mu.Remove(enc(v))`.
+func RemoveValue[T any](mu *MuHash, v T, enc func(T) []byte) {
+	mu.Remove(enc(v))
+}
@@ -0,0 +1,65 @@
+//go:build go1.18
+// +build go1.18
+
+package muhash
+
+import (
+	"encoding/binary"
+	"fmt"
+	"testing"
+)
+
+type testOutpoint struct {
+	txID  Hash
+	index uint32
+}
+
+func encodeTestOutpoint(outpoint testOutpoint) []byte {
+	encoded := make([]byte, HashSize+4)
+	copy(encoded, outpoint.txID[:])
+	binary.LittleEndian.PutUint32(encoded[HashSize:], outpoint.index)
+	return encoded
+}
+
+func TestAddValue(t *testing.T) {
+	t.Parallel()
+	outpoints := []testOutpoint{{Hash{1}, 0}, {Hash{1}, 1}, {Hash{2}, 0}}
+	typed, expected := NewMuHash(), NewMuHash()
+	for _, outpoint := range outpoints {
+		AddValue(typed, outpoint, encodeTestOutpoint)
+		expected.Add(encodeTestOutpoint(outpoint))
+	}
+	if typed.Finalize() != expected.Finalize() {
+		t.Fatalf("Expected %s == %s", typed.Finalize(), expected.Finalize())
+	}
+	for _, outpoint := range outpoints {
+		RemoveValue(typed, outpoint, encodeTestOutpoint)
+	}
+	if typed.Finalize() != EmptyMuHashHash {
+		t.Fatalf("Expected %s == %s", typed.Finalize(), EmptyMuHashHash)
+	}
+}
+
+func ExampleAddValue() {
+	type record struct {
+		key   string
+		value uint64
+	}
+	encodeRecord := func(r record) []byte {
+		encoded := make([]byte, binary.MaxVarintLen64+len(r.key)+8)
+		n := binary.PutUvarint(encoded, uint64(len(r.key)))
+		n += copy(encoded[n:], r.key)
+		binary.LittleEndian.PutUint64(encoded[n:], r.value)
+		return encoded[:n+8]
+	}
+
+	mu := NewMuHash()
+	AddValue(mu, record{"a", 1}, encodeRecord)
+	AddValue(mu, record{"b", 2}, encodeRecord)
+	RemoveValue(mu, record{"a", 1}, encodeRecord)
+
+	expected := NewMuHash()
+	expected.Add(encodeRecord(record{"b", 2}))
+	fmt.Println(mu.Finalize() == expected.Finalize())
+	// Output: true
+}
@@ -1,17 +1,50 @@
 package muhash
 
 import (
-	"math/big"
+	"math"
 	"math/bits"
 )
 
 const (
+	wordSizeInBytes = bits.UintSize / 8
+	wordSize        = bits.UintSize
+	elementWordSize = elementByteSize / wordSizeInBytes
+
 	limbs   = elementWordSize
 	maxUint = ^uint(0)
 )
 
+// uint3072 is an element of the multiplicative group, it might not be fully reduced (see IsOverflow).
+//
+// Only the multiplication itself in Mul and Square runs in constant time.
+// The final reduction of Mul and Square, IsOverflow, IsZero and the inversion in Divide
+// branch on the value, and the inversion dominates normalizing a set for Serialize and Finalize, so MuHash isn't
+// constant time and a constant-time reduction alone wouldn't make it so.
 type uint3072 [limbs]uint
 
+// field3072 is the part of the method set that both implementations of the multiplicative group, uint3072 and
+// the cgo num3072 (built with `-tags=muhash_cgo`), must have, so that a backend missing a method fails to build at its
+// own declaration. Mul and Divide take the backend's own type so they can't be part of the
+// interface, instead each backend asserts their signatures next to its field3072 assertion.
+//
+// MuHash uses uint3072 directly rather than through this interface, since storing the numerator and denominator
+// as interfaces would allocate them and add a dynamic call to every multiplication.
+// Backends are selected with build tags behind uint3072's methods instead: Mul is implemented in assembly in
+// uint3072_amd64.go and in Go in uint3072_generic.go, and a new backend is another build tagged implementation
+// of Mul. Every backend runs the full test suite by building the tests with its tags.
+type field3072 interface {
+	IsOverflow() bool
+	FullReduce()
+	SetToOne()
+	Square()
+}
+
+var (
+	_ field3072                = (*uint3072)(nil)
+	_ func(lhs, rhs *uint3072) = (*uint3072).Mul
+	_ func(lhs, rhs *uint3072) = (*uint3072).Divide
+)
+
 // Extract the lowest limb of [low,high,carry] into n, and left shift the number by 1 limb.
 func extract3(low, high, carry, n *uint) {
 	*n = *low
@@ -26,6 +59,9 @@ func mul(low, high *uint, a, b uint) {
 }
 
 // [c0,c1,c2] += n * [d0,d1,d2]. c2 is 0 initially
+// No carry is lost: c0 + d0*n and c1 + tmpHigh + d1*n each fit in two words, so adding the carries to the high words
+// of the products can't overflow them. c2 is the top word of the result modulo 2^3w, which is exact as long as
+// the result fits in three words. Square only calls it with a small d2 and n = primeDiff, so it always fits.
 func mulnadd3(c0, c1, c2 *uint, d0, d1, d2, n uint) {
 	var carry, tmpLow uint
 	tmpHigh, tmpLow := bits.Mul(d0, n)
@@ -92,7 +128,8 @@ func assert(cond bool) {
 	}
 }
 
-func (lhs *uint3072) Mul(rhs *uint3072) {
+// mulGeneric is the pure Go implementation of Mul.
+func (lhs *uint3072) mulGeneric(rhs *uint3072) {
 	var carryLow, carryHigh, carryHighest uint
 	var tmp uint3072
 	// Compute limbs 0..N-2 of lhs*rhs into tmp, including one reduction.
@@ -158,17 +195,21 @@ func (lhs *uint3072) Mul(rhs *uint3072) {
 	}
 
 	assert(carryHighest == 0)
-	assert(carryLow == 0 || carryLow == 1)
+	lhs.finalReduce(carryLow)
+}
 
-	// Perform up to two more reductions if the internal state has already
-	// overflown the MAX of uint3072 or if it is larger than the modulus or
-	// if both are the case.
+// finalReduce performs up to two more reductions if the internal state has already
+// overflown the MAX of uint3072 or if it is larger than the modulus or
+// if both are the case.
+func (lhs *uint3072) finalReduce(carry uint) {
+	assert(carry == 0 || carry == 1)
 	if lhs.IsOverflow() {
 		lhs.FullReduce()
 	}
-	if carryLow > 0 {
+	if carry > 0 {
 		lhs.FullReduce()
 	}
+	checkReduced(lhs)
 }
 
 func (lhs *uint3072) Square() {
@@ -221,75 +262,72 @@ func (lhs *uint3072) Square() {
 	if low > 0 {
 		lhs.FullReduce()
 	}
+	checkReduced(lhs)
 }
 
 func (lhs *uint3072) Divide(rhs *uint3072) {
 	if lhs.IsOverflow() {
 		lhs.FullReduce()
 	}
-	if rhs.IsOverflow() {
-		rhs.FullReduce()
-	}
-
-	rightWords := make([]big.Word, limbs)
-	for i := range rightWords {
-		rightWords[i] = big.Word(rhs[i])
-	}
-	var right big.Int
-	right.SetBits(rightWords)
-	right.ModInverse(&right, prime)
-
-	var inv uint3072
-	for i, word := range right.Bits() {
-		inv[i] = uint(word)
-	}
+	inv := rhs.modInverse()
 	lhs.Mul(&inv)
 	if lhs.IsOverflow() {
 		lhs.FullReduce()
 	}
 }
 
-// lhs = lhs^(2^exp) * mul
-func (lhs *uint3072) squareNmul(exp int, mul *uint3072) {
-	for j := 0; j < exp; j++ {
-		lhs.Square()
+// modInverse returns the modular inverse of lhs using safegcd, which is much faster than exponentiating it to p-2
+// (see GetInverse in the tests) and doesn't allocate. lhs isn't modified.
+// Zero doesn't have a modular inverse, so zero is returned for it.
+func (lhs *uint3072) modInverse() uint3072 {
+	// Reduce a copy so that the caller's value isn't modified.
+	reduced := *lhs
+	if reduced.IsOverflow() {
+		reduced.FullReduce()
 	}
-	lhs.Mul(mul)
+	if reduced[0] != 0 && reduced.isUint32() {
+		return smallInverse(uint32(reduced[0]))
+	}
+	return safegcdInverse(&reduced)
 }
 
-func (lhs *uint3072) GetInverse() uint3072 {
-	// For fast exponentiation a sliding window exponentiation with repunit
-	// precomputation is utilized. See "Fast Point Decompression for Standard
-	// Elliptic Curves" (Brumley, Järvinen, 2008).
+// isUint32 returns true if the number is smaller than 2^32.
+func (lhs *uint3072) isUint32() bool {
+	for i := 1; i < len(lhs); i++ {
+		if lhs[i] != 0 {
+			return false
+		}
+	}
+	return uint64(lhs[0]) <= math.MaxUint32
+}
 
-	var powers [12]uint3072 // powers[i] = a^(2^(2^i)-1)
-	var res uint3072
+// batchInverse inverts all the elements in place using Montgomery's trick,
+// which costs a single modular inversion and 3(n-1) multiplications.
+// Zero elements (including ones equal to the prime) have no inverse, they're skipped and left as zero.
+func batchInverse(elements []uint3072) {
+	// prefixes[i] is the product of all the non zero elements before i.
+	prefixes := make([]uint3072, len(elements))
+	acc := one()
+	for i := range elements {
+		if elements[i].IsOverflow() {
+			elements[i].FullReduce()
+		}
+		prefixes[i] = acc
+		if !elements[i].IsZero() {
+			acc.Mul(&elements[i])
+		}
+	}
 
-	powers[0] = *lhs
-	for i := 0; i < 11; i++ {
-		powers[i+1] = powers[i]
-		for j := 0; j < (1 << i); j++ {
-			powers[i+1].Square()
+	acc = acc.modInverse()
+	for i := len(elements) - 1; i >= 0; i-- {
+		if elements[i].IsZero() {
+			continue
 		}
-		powers[i+1].Mul(&powers[i])
+		inv := prefixes[i]
+		inv.Mul(&acc)
+		acc.Mul(&elements[i])
+		elements[i] = inv
 	}
-	res = powers[11]
-
-	res.squareNmul(512, &powers[9])
-	res.squareNmul(256, &powers[8])
-	res.squareNmul(128, &powers[7])
-	res.squareNmul(64, &powers[6])
-	res.squareNmul(32, &powers[5])
-	res.squareNmul(8, &powers[3])
-	res.squareNmul(2, &powers[1])
-	res.squareNmul(1, &powers[0])
-	res.squareNmul(5, &powers[2])
-	res.squareNmul(3, &powers[0])
-	res.squareNmul(2, &powers[0])
-	res.squareNmul(4, &powers[0])
-	res.squareNmul(4, &powers[1])
-	res.squareNmul(3, &powers[0])
-	return res
 }
 
 func (lhs *uint3072) IsOverflow() bool {
@@ -304,20 +342,16 @@ func (lhs *uint3072) IsOverflow() bool {
 	return true
 }
 
-func (lhs *uint3072) FullReduce() {
-	low := uint(primeDiff)
-	var high uint
-	for i := 0; i < limbs; i++ {
-		var carry uint
-
-		low, carry = bits.Add(low, lhs[i], carry)
-		high, carry = bits.Add(high, 0, carry)
-		high, carry = bits.Add(high, 0, carry)
+func (lhs *uint3072) IsZero() bool {
+	return *lhs == uint3072{}
+}
 
-		// extract
-		lhs[i] = low
-		low = high
-		high = carry
+func (lhs *uint3072) FullReduce() {
+	// Subtracting the prime is adding primeDiff and dropping the 2^3072 carry out of the last limb.
+	// After the first limb the carry is at most one, so unlike addnextract2 a single carry word suffices.
+	carry := uint(primeDiff)
+	for i := range lhs {
+		lhs[i], carry = bits.Add(lhs[i], carry, 0)
 	}
 }
 
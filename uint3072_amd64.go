@@ -0,0 +1,22 @@
+//go:build amd64 && !purego && !muhash_cgo
+// +build amd64,!purego,!muhash_cgo
+
+package muhash
+
+import "github.com/kaspanet/go-muhash/internal/fieldbackend"
+
+// mulAsm sets z to x*y reduced once by the modulus, and returns the carry that still needs to be reduced.
+// z may alias x or y.
+//
+//go:noescape
+func mulAsm(z, x, y *uint3072) uint
+
+func (lhs *uint3072) Mul(rhs *uint3072) {
+	// This is a branch rather than a function variable so that the arguments of Mul don't escape to the heap.
+	if fieldbackend.UseGeneric() {
+		lhs.mulGeneric(rhs)
+		return
+	}
+	carry := mulAsm(lhs, lhs, rhs)
+	lhs.finalReduce(carry)
+}
@@ -0,0 +1,158 @@
+//go:build amd64 && !purego && !muhash_cgo
+// +build amd64,!purego,!muhash_cgo
+
+#include "textflag.h"
+
+#define PRIME_DIFF $1103717
+
+// [c0,c1,c2] += x * y
+#define MULADD3(x, y, c0, c1, c2) \
+	MOVQ	x, AX \
+	MULQ	y \
+	ADDQ	AX, c0 \
+	ADCQ	DX, c1 \
+	ADCQ	$0, c2
+
+// func mulAsm(z, x, y *uint3072) uint
+// Computes the full 6144 bit product of x and y column by column into a local buffer, folds the upper
+// 3072 bits back by multiplying them with PRIME_DIFF (2^3072 = PRIME_DIFF mod p), and then folds the
+// remaining carry limb the same way. Returns the carry bit that is left after that.
+TEXT ·mulAsm(SB), $768-32
+	MOVQ	x+8(FP), BX
+	MOVQ	y+16(FP), CX
+	XORQ	R8, R8
+	XORQ	R9, R9
+	XORQ	R10, R10
+
+	// Columns 0..47: tmp[k] = sum(x[i] * y[k-i]) for i in 0..k
+	XORQ	R11, R11
+lowColumns:
+	MOVQ	BX, SI
+	LEAQ	(CX)(R11*8), DI
+	LEAQ	1(R11), R12
+	TESTQ	$1, R12
+	JZ	lowPairs
+	MULADD3(0(SI), 0(DI), R8, R9, R10)
+	ADDQ	$8, SI
+	SUBQ	$8, DI
+	DECQ	R12
+	JZ	lowDone
+lowPairs:
+	MULADD3(0(SI), 0(DI), R8, R9, R10)
+	MULADD3(8(SI), -8(DI), R8, R9, R10)
+	ADDQ	$16, SI
+	SUBQ	$16, DI
+	SUBQ	$2, R12
+	JNZ	lowPairs
+lowDone:
+	MOVQ	R8, 0(SP)(R11*8)
+	MOVQ	R9, R8
+	MOVQ	R10, R9
+	XORQ	R10, R10
+	INCQ	R11
+	CMPQ	R11, $48
+	JLT	lowColumns
+
+	// Columns 48..94: tmp[k] = sum(x[i] * y[k-i]) for i in k-47..47
+highColumns:
+	LEAQ	-376(BX)(R11*8), SI
+	LEAQ	376(CX), DI
+	MOVQ	$95, R12
+	SUBQ	R11, R12
+	TESTQ	$1, R12
+	JZ	highPairs
+	MULADD3(0(SI), 0(DI), R8, R9, R10)
+	ADDQ	$8, SI
+	SUBQ	$8, DI
+	DECQ	R12
+	JZ	highDone
+highPairs:
+	MULADD3(0(SI), 0(DI), R8, R9, R10)
+	MULADD3(8(SI), -8(DI), R8, R9, R10)
+	ADDQ	$16, SI
+	SUBQ	$16, DI
+	SUBQ	$2, R12
+	JNZ	highPairs
+highDone:
+	MOVQ	R8, 0(SP)(R11*8)
+	MOVQ	R9, R8
+	MOVQ	R10, R9
+	XORQ	R10, R10
+	INCQ	R11
+	CMPQ	R11, $95
+	JLT	highColumns
+	MOVQ	R8, 760(SP)
+
+	// z = tmp[0:48] + tmp[48:96] * PRIME_DIFF, keeping the carry limb in R10.
+	MOVQ	z+0(FP), DI
+	MOVQ	PRIME_DIFF, R11
+	XORQ	R10, R10
+	XORQ	R9, R9
+reduce:
+	MOVQ	384(SP)(R9*8), AX
+	MULQ	R11
+	ADDQ	0(SP)(R9*8), AX
+	ADCQ	$0, DX
+	ADDQ	R10, AX
+	ADCQ	$0, DX
+	MOVQ	AX, (DI)(R9*8)
+	MOVQ	DX, R10
+	INCQ	R9
+	CMPQ	R9, $48
+	JLT	reduce
+
+	// z += carry * PRIME_DIFF
+	MOVQ	R10, AX
+	MULQ	R11
+	ADDQ	AX, 0(DI)
+	ADCQ	DX, 8(DI)
+	ADCQ	$0, 16(DI)
+	ADCQ	$0, 24(DI)
+	ADCQ	$0, 32(DI)
+	ADCQ	$0, 40(DI)
+	ADCQ	$0, 48(DI)
+	ADCQ	$0, 56(DI)
+	ADCQ	$0, 64(DI)
+	ADCQ	$0, 72(DI)
+	ADCQ	$0, 80(DI)
+	ADCQ	$0, 88(DI)
+	ADCQ	$0, 96(DI)
+	ADCQ	$0, 104(DI)
+	ADCQ	$0, 112(DI)
+	ADCQ	$0, 120(DI)
+	ADCQ	$0, 128(DI)
+	ADCQ	$0, 136(DI)
+	ADCQ	$0, 144(DI)
+	ADCQ	$0, 152(DI)
+	ADCQ	$0, 160(DI)
+	ADCQ	$0, 168(DI)
+	ADCQ	$0, 176(DI)
+	ADCQ	$0, 184(DI)
+	ADCQ	$0, 192(DI)
+	ADCQ	$0, 200(DI)
+	ADCQ	$0, 208(DI)
+	ADCQ	$0, 216(DI)
+	ADCQ	$0, 224(DI)
+	ADCQ	$0, 232(DI)
+	ADCQ	$0, 240(DI)
+	ADCQ	$0, 248(DI)
+	ADCQ	$0, 256(DI)
+	ADCQ	$0, 264(DI)
+	ADCQ	$0, 272(DI)
+	ADCQ	$0, 280(DI)
+	ADCQ	$0, 288(DI)
+	ADCQ	$0, 296(DI)
+	ADCQ	$0, 304(DI)
+	ADCQ	$0, 312(DI)
+	ADCQ	$0, 320(DI)
+	ADCQ	$0, 328(DI)
+	ADCQ	$0, 336(DI)
+	ADCQ	$0, 344(DI)
+	ADCQ	$0, 352(DI)
+	ADCQ	$0, 360(DI)
+	ADCQ	$0, 368(DI)
+	ADCQ	$0, 376(DI)
+	MOVQ	$0, AX
+	ADCQ	$0, AX
+	MOVQ	AX, ret+24(FP)
+	RET
@@ -0,0 +1,8 @@
+//go:build !amd64 || purego || muhash_cgo
+// +build !amd64 purego muhash_cgo
+
+package muhash
+
+func (lhs *uint3072) Mul(rhs *uint3072) {
+	lhs.mulGeneric(rhs)
+}
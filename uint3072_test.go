@@ -1,240 +1,85 @@
 package muhash
 
 import (
+	"math/big"
 	"math/rand"
+	"os"
+	"path/filepath"
 	"runtime"
 	"sync"
 	"testing"
 )
 
-func Test_mul(t *testing.T) {
+// TestFieldParameters checks the assumptions the arithmetic makes about the prime 2^elementBitSize - primeDiff,
+// so that changing the field parameters fails here instead of silently corrupting the arithmetic.
+func TestFieldParameters(t *testing.T) {
 	t.Parallel()
-	type Test struct {
-		a            uint
-		b            uint
-		expectedLow  uint
-		expectedHigh uint
-	}
-	tests := []Test{
-		{
-			a:            ^uint(0),
-			b:            ^uint(0),
-			expectedLow:  1,
-			expectedHigh: 18446744073709551614,
-		},
-		{
-			a:            ^uint(0) - 100,
-			b:            ^uint(0) - 30,
-			expectedLow:  3131,
-			expectedHigh: 18446744073709551484,
-		},
-	}
-	for i, test := range tests {
-		var low, high uint
-		mul(&low, &high, test.a, test.b)
-		if low != test.expectedLow {
-			t.Fatalf("Test: %d. Expected: %d, found: %d", i, test.expectedLow, low)
-		}
-		if high != test.expectedHigh {
-			t.Fatalf("Test: %d. Expected: %d, found: %d", i, test.expectedHigh, high)
-		}
+	if !prime.ProbablyPrime(20) {
+		t.Fatalf("Expected 2^%d - %d to be a prime", elementBitSize, primeDiff)
 	}
-}
-
-func Test_mulnadd3(t *testing.T) {
-	t.Parallel()
-	type Test struct {
-		c0         uint
-		c1         uint
-		c2         uint
-		d0         uint
-		d1         uint
-		d2         uint
-		n          uint
-		expectedC0 uint
-		expectedC1 uint
-		expectedC2 uint
-	}
-	tests := []Test{
-		{
-			c0:         ^uint(0) - 99,
-			c1:         ^uint(0) - 75,
-			c2:         ^uint(0) - 100,
-			d0:         ^uint(0) - 30,
-			d1:         ^uint(0) - 3452,
-			d2:         ^uint(0) - 321,
-			n:          ^uint(0) - 543,
-			expectedC0: 16764,
-			expectedC1: 1877782,
-			expectedC2: 171173,
-		},
-		{
-			c0:         0,
-			c1:         ^uint(0) - 32432432,
-			c2:         ^uint(0) - 534532431432423,
-			d0:         ^uint(0) - 534543534534,
-			d1:         1,
-			d2:         ^uint(0) - 3242353456341,
-			n:          ^uint(0) - 546546456543,
-			expectedC0: 11788773271371804448,
-			expectedC1: 18446742446040687397,
-			expectedC2: 10322986003028211010,
-		},
-	}
-	for i, test := range tests {
-		mulnadd3(&test.c0, &test.c1, &test.c2, test.d0, test.d1, test.d2, test.n)
-		if test.c0 != test.expectedC0 {
-			t.Fatalf("Test: %d. Expected c0: %d, found: %d", i, test.expectedC0, test.c0)
-		}
-		if test.c1 != test.expectedC1 {
-			t.Fatalf("Test: %d. Expected c1: %d, found: %d", i, test.expectedC1, test.c1)
-		}
-		if test.c2 != test.expectedC2 {
-			t.Fatalf("Test: %d. Expected c2: %d, found: %d", i, test.expectedC2, test.c2)
-		}
+	if elementBitSize%64 != 0 || limbs*wordSize != elementBitSize {
+		t.Fatalf("Expected the %d bits to be a whole number of 32 and 64 bit limbs", elementBitSize)
 	}
-}
-
-func Test_muln2(t *testing.T) {
-	t.Parallel()
-	type Test struct {
-		low          uint
-		high         uint
-		n            uint
-		expectedLow  uint
-		expectedHigh uint
-	}
-	tests := []Test{
-		{
-			low:          ^uint(0) - 99,
-			high:         ^uint(0) - 75,
-			n:            ^uint(0) - 543,
-			expectedLow:  54400,
-			expectedHigh: 40700,
-		},
-		{
-			low:          0,
-			high:         ^uint(0) - 32432432,
-			n:            ^uint(0) - 546546456543,
-			expectedLow:  0,
-			expectedHigh: 17725831333250691552,
-		},
-	}
-	for i, test := range tests {
-		muln2(&test.low, &test.high, test.n)
-		if test.low != test.expectedLow {
-			t.Fatalf("Test: %d. Expected low: %d, found: %d", i, test.expectedLow, test.low)
-		}
-		if test.high != test.expectedHigh {
-			t.Fatalf("Test: %d. Expected high: %d, found: %d", i, test.expectedHigh, test.high)
-		}
+	// smallInverse, the reductions of Mul and Square, and mulnadd3's three word results assume primeDiff fits in 32 bits,
+	// and safegcd needs an odd modulus.
+	if primeDiff >= 1<<32 || primeDiff%2 == 0 {
+		t.Fatalf("Expected primeDiff to be odd and smaller than 2^32, found %d", primeDiff)
+	}
+	// safegcd needs room for two more bits than the prime in its signed 62 bit limbs.
+	if signed62Limbs*62 < elementBitSize+2 {
+		t.Fatalf("Expected %d signed 62 bit limbs to fit %d bits", signed62Limbs, elementBitSize+2)
+	}
+	if uint3072ToBigInt(&primeUint3072).Cmp(prime) != 0 {
+		t.Fatalf("Expected primeUint3072 to be the prime, found %v", primeUint3072)
 	}
 }
 
-func Test_muladd3(t *testing.T) {
-	t.Parallel()
-	type Test struct {
-		low           uint
-		high          uint
-		carry         uint
-		a             uint
-		b             uint
-		expectedLow   uint
-		expectedHigh  uint
-		expectedCarry uint
-	}
-	tests := []Test{
-		{
-			low:           ^uint(0) - 99,
-			high:          ^uint(0) - 75,
-			carry:         ^uint(0) - 100,
-			a:             ^uint(0) - 30,
-			b:             ^uint(0) - 3452,
-			expectedLow:   106943,
-			expectedHigh:  18446744073709548057,
-			expectedCarry: 18446744073709551516,
-		},
-		{
-			low:           0,
-			high:          ^uint(0) - 32432432,
-			carry:         ^uint(0) - 534532431432423,
-			a:             ^uint(0) - 534543534534,
-			b:             1,
-			expectedLow:   18446743539166017081,
-			expectedHigh:  18446744073677119183,
-			expectedCarry: 18446209541278119192,
-		},
-	}
-	for i, test := range tests {
-		muladd3(&test.low, &test.high, &test.carry, test.a, test.b)
-		if test.low != test.expectedLow {
-			t.Fatalf("Test: %d. %#v: %d, found: %d", i, test.expectedLow, test.expectedLow, test.low)
-		}
-		if test.high != test.expectedHigh {
-			t.Fatalf("Test: %d. %#v: %d, found: %d", i, test.expectedHigh, test.expectedHigh, test.high)
-		}
-		if test.carry != test.expectedCarry {
-			t.Fatalf("Test: %d. %#v: %d, found: %d", i, test.expectedCarry, test.expectedCarry, test.carry)
-		}
+// squareNmul squares lhs exp times and then multiplies it by mul.
+// It squares using Mul, which is faster than Square (see BenchmarkUint3072_Square).
+func (lhs *uint3072) squareNmul(exp int, mul *uint3072) {
+	for j := 0; j < exp; j++ {
+		lhs.Mul(lhs)
 	}
+	lhs.Mul(mul)
 }
 
-func Test_muldbladd3(t *testing.T) {
-	t.Parallel()
-	type Test struct {
-		low           uint
-		high          uint
-		carry         uint
-		a             uint
-		b             uint
-		expectedLow   uint
-		expectedHigh  uint
-		expectedCarry uint
-	}
-	tests := []Test{
-		{
-			low:           ^uint(0) - 99,
-			high:          ^uint(0) - 75,
-			carry:         ^uint(0) - 100,
-			a:             ^uint(0) - 30,
-			b:             ^uint(0) - 3452,
-			expectedLow:   213986,
-			expectedHigh:  18446744073709544573,
-			expectedCarry: 18446744073709551517,
-		},
-		{
-			low:           0,
-			high:          ^uint(0) - 32432432,
-			carry:         ^uint(0) - 534532431432423,
-			a:             ^uint(0) - 534543534534,
-			b:             1,
-			expectedLow:   18446743004622482546,
-			expectedHigh:  18446744073677119184,
-			expectedCarry: 18446209541278119192,
-		},
-		{
-			low:           0,
-			high:          0,
-			carry:         0,
-			a:             1,
-			b:             1,
-			expectedLow:   2,
-			expectedHigh:  0,
-			expectedCarry: 0,
-		},
-	}
-	for i, test := range tests {
-		muldbladd3(&test.low, &test.high, &test.carry, test.a, test.b)
-		if test.low != test.expectedLow {
-			t.Fatalf("Test: %d. %#v: %d, found: %d", i, test.expectedLow, test.expectedLow, test.low)
-		}
-		if test.high != test.expectedHigh {
-			t.Fatalf("Test: %d. %#v: %d, found: %d", i, test.expectedHigh, test.expectedHigh, test.high)
-		}
-		if test.carry != test.expectedCarry {
-			t.Fatalf("Test: %d. %#v: %d, found: %d", i, test.expectedCarry, test.expectedCarry, test.carry)
+// GetInverse inverts by exponentiating to p-2 with an addition chain specific to this prime. The library inverts
+// with safegcd (modInverse) instead, this is kept as an independent reference to check it against.
+func (lhs *uint3072) GetInverse() uint3072 {
+	// For fast exponentiation a sliding window exponentiation with repunit
+	// precomputation is utilized. See "Fast Point Decompression for Standard
+	// Elliptic Curves" (Brumley, Järvinen, 2008).
+	// The chain uses 3071 squarings, the least possible for a 3072 bit exponent,
+	// and only 25 multiplications, so the squarings are what determine its speed.
+
+	var powers [12]uint3072 // powers[i] = a^(2^(2^i)-1)
+	var res uint3072
+
+	powers[0] = *lhs
+	for i := 0; i < 11; i++ {
+		powers[i+1] = powers[i]
+		for j := 0; j < (1 << i); j++ {
+			powers[i+1].Mul(&powers[i+1])
 		}
+		powers[i+1].Mul(&powers[i])
 	}
+	res = powers[11]
+
+	res.squareNmul(512, &powers[9])
+	res.squareNmul(256, &powers[8])
+	res.squareNmul(128, &powers[7])
+	res.squareNmul(64, &powers[6])
+	res.squareNmul(32, &powers[5])
+	res.squareNmul(8, &powers[3])
+	res.squareNmul(2, &powers[1])
+	res.squareNmul(1, &powers[0])
+	res.squareNmul(5, &powers[2])
+	res.squareNmul(3, &powers[0])
+	res.squareNmul(2, &powers[0])
+	res.squareNmul(4, &powers[0])
+	res.squareNmul(4, &powers[1])
+	res.squareNmul(3, &powers[0])
+	return res
 }
 
 func TestUint3072_GetInverse(t *testing.T) {
@@ -254,6 +99,51 @@ func TestUint3072_GetInverse(t *testing.T) {
 	}
 }
 
+func TestUint3072_GetInverseRandom(t *testing.T) {
+	t.Parallel()
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 20; i++ {
+		var element uint3072
+		for j := range element {
+			element[j] = uint(r.Uint64())
+		}
+		if element.IsOverflow() {
+			element.FullReduce()
+		}
+		inv := element.GetInverse()
+		expected := element.modInverse()
+		if inv != expected {
+			t.Fatalf("Expected GetInverse to match the big.Int inverse, found: %v != %v", inv, expected)
+		}
+		product := element
+		product.Mul(&inv)
+		if !uint3072equalToUint(&product, 1) {
+			t.Fatalf("Expected %v * %v to be 1, found: %v", element, inv, product)
+		}
+		if again := inv.GetInverse(); again != element {
+			t.Fatalf("Expected double inverting to be equal, found: %v != %v", again, element)
+		}
+	}
+}
+
+func TestUint3072_SquareMatchesMul(t *testing.T) {
+	t.Parallel()
+	r := rand.New(rand.NewSource(0))
+	for i := 0; i < loopsN; i++ {
+		var element uint3072
+		for j := range element {
+			element[j] = uint(r.Uint64())
+		}
+		squared := element
+		squared.Square()
+		expected := element
+		expected.Mul(&element)
+		if squared != expected {
+			t.Fatalf("Expected Square(%v) to match Mul, found: %v != %v", element, squared, expected)
+		}
+	}
+}
+
 func uint3072equalToUint(a *uint3072, b uint) bool {
 	if a[0] != b {
 		return false
@@ -316,6 +206,133 @@ func TestUint3072_DivOverflow(t *testing.T) {
 	}
 }
 
+func TestUint3072_FullReduce(t *testing.T) {
+	t.Parallel()
+	var max uint3072
+	for i := range max {
+		max[i] = maxUint
+	}
+	prime := max
+	prime[0] -= primeDiff - 1
+	primePlusOne := prime
+	primePlusOne[0]++
+	values := []uint3072{{}, one(), max, prime, primePlusOne}
+	r := rand.New(rand.NewSource(0))
+	for i := 0; i < 100; i++ {
+		// All ones with random low limbs, around and above the prime, and fully random numbers.
+		value := max
+		value[0] = uint(r.Uint64())
+		if i%2 == 0 {
+			value[1] = uint(r.Uint64())
+		}
+		values = append(values, value)
+		for j := range value {
+			value[j] = uint(r.Uint64())
+		}
+		values = append(values, value)
+	}
+
+	// FullReduce adds primeDiff modulo 2^3072, which subtracts the prime from numbers that overflow it,
+	// and is also how finalReduce folds a carry out of the last limb back in.
+	modulus := new(big.Int).Lsh(big.NewInt(1), elementBitSize)
+	for _, value := range values {
+		expected := uint3072ToBigInt(&value)
+		expected.Add(expected, big.NewInt(primeDiff))
+		expected.Mod(expected, modulus)
+		reduced := value
+		reduced.FullReduce()
+		if uint3072ToBigInt(&reduced).Cmp(expected) != 0 {
+			t.Fatalf("Expected FullReduce(%v) to be %x, found: %v", value, expected, reduced)
+		}
+	}
+}
+
+// TestUint3072_GetInverseMatchesBigInt checks GetInverse and Divide against math/big. The inputs are generated as bytes,
+// so they're the same with 48 limbs on 64 bit and with 96 limbs on 32 bit (GOARCH=386). The addition chain of GetInverse
+// only depends on the 3072 bit exponent, not on the number of limbs, and this verifies it on both.
+func TestUint3072_GetInverseMatchesBigInt(t *testing.T) {
+	t.Parallel()
+	r := rand.New(rand.NewSource(5))
+	inputs := []*big.Int{big.NewInt(1), big.NewInt(2), big.NewInt(primeDiff), new(big.Int).Sub(prime, big.NewInt(1))}
+	for i := 0; i < 5; i++ {
+		inputs = append(inputs, new(big.Int).Rand(r, prime))
+	}
+	for i, input := range inputs {
+		num := bigIntToUint3072(input)
+		inverse := num.GetInverse()
+		expected := new(big.Int).ModInverse(input, prime)
+		if uint3072ToBigInt(&inverse).Cmp(expected) != 0 {
+			t.Fatalf("Input #%d: Expected the inverse of %x to be %x, found %x", i, input, expected, uint3072ToBigInt(&inverse))
+		}
+		if again := inverse.GetInverse(); again != num {
+			t.Fatalf("Input #%d: Expected double inverting to be equal, found: %v != %v", i, again, num)
+		}
+
+		dividend := new(big.Int).Rand(r, prime)
+		quotient := bigIntToUint3072(dividend)
+		quotient.Divide(&num)
+		expected.Mul(expected, dividend)
+		expected.Mod(expected, prime)
+		if uint3072ToBigInt(&quotient).Cmp(expected) != 0 {
+			t.Fatalf("Input #%d: Expected %x / %x to be %x, found %x", i, dividend, input, expected, uint3072ToBigInt(&quotient))
+		}
+	}
+}
+
+// TestUint3072_accumulationCarries checks the three word accumulation helpers against math/big, with operands chosen to
+// maximize their carries, on both 32 and 64 bit words.
+func TestUint3072_accumulationCarries(t *testing.T) {
+	t.Parallel()
+	words := []uint{0, 1, 2, maxUint, maxUint - 1, maxUint >> 1, primeDiff}
+	r := rand.New(rand.NewSource(0))
+	for i := 0; i < 5; i++ {
+		words = append(words, uint(r.Uint64()))
+	}
+	wordsToBig := func(w ...uint) *big.Int {
+		n := new(big.Int)
+		for i := len(w) - 1; i >= 0; i-- {
+			n.Lsh(n, wordSize)
+			n.Add(n, new(big.Int).SetUint64(uint64(w[i])))
+		}
+		return n
+	}
+	modulus := new(big.Int).Lsh(big.NewInt(1), 3*wordSize)
+	check := func(name string, low, high, carry uint, expected *big.Int) {
+		expected.Mod(expected, modulus)
+		if result := wordsToBig(low, high, carry); result.Cmp(expected) != 0 {
+			t.Fatalf("%s: Expected %x, found %x", name, expected, result)
+		}
+	}
+
+	for _, a := range words {
+		for _, b := range words {
+			for _, c := range words {
+				product := new(big.Int).Mul(wordsToBig(a), wordsToBig(b))
+
+				low, high, carry := c, c, c
+				muladd3(&low, &high, &carry, a, b)
+				check("muladd3", low, high, carry, new(big.Int).Add(wordsToBig(c, c, c), product))
+
+				low, high, carry = c, c, c
+				muldbladd3(&low, &high, &carry, a, b)
+				doubled := new(big.Int).Lsh(product, 1)
+				check("muldbladd3", low, high, carry, doubled.Add(doubled, wordsToBig(c, c, c)))
+
+				// c2 is zero initially, and the whole three word result is kept.
+				low, high, carry = c, c, 0
+				mulnadd3(&low, &high, &carry, a, b, c, primeDiff)
+				scaled := new(big.Int).Mul(wordsToBig(a, b, c), big.NewInt(primeDiff))
+				check("mulnadd3", low, high, carry, scaled.Add(scaled, wordsToBig(c, c)))
+
+				low, high, carry = a, b, 0
+				mulnadd3(&low, &high, &carry, maxUint, maxUint, c, maxUint)
+				scaled = new(big.Int).Mul(wordsToBig(maxUint, maxUint, c), wordsToBig(maxUint))
+				check("mulnadd3 max", low, high, carry, scaled.Add(scaled, wordsToBig(a, b)))
+			}
+		}
+	}
+}
+
 func TestUint3072_MulMax(t *testing.T) {
 	t.Parallel()
 	var max uint3072
@@ -352,3 +369,263 @@ func TestUint3072MulDiv(t *testing.T) {
 		t.Errorf("start should be 1 but it isn't: start: '%x', one: '%x'\n", start, one())
 	}
 }
+
+func TestUint3072_DivideDoesNotModifyDivisor(t *testing.T) {
+	t.Parallel()
+	var divisor uint3072
+	for i := range divisor {
+		divisor[i] = maxUint
+	}
+	divisor[0] -= primeDiff / 2
+	if !divisor.IsOverflow() {
+		t.Fatalf("Expected %v to be overflown", divisor)
+	}
+	divisorCopy := divisor
+	lhs := one()
+	lhs.Divide(&divisor)
+	if divisor != divisorCopy {
+		t.Fatalf("Divide modified its divisor: %v != %v", divisor, divisorCopy)
+	}
+	lhs.Mul(&divisor)
+	if !uint3072equalToUint(&lhs, 1) {
+		t.Fatalf("Expected %v to be 1", lhs)
+	}
+}
+
+func TestUint3072_DivideMatchesGetInverse(t *testing.T) {
+	t.Parallel()
+	r := rand.New(rand.NewSource(0))
+	for i := 0; i < 5; i++ {
+		var lhs, rhs uint3072
+		for j := range lhs {
+			lhs[j] = uint(r.Uint64())
+			rhs[j] = uint(r.Uint64())
+		}
+		quotient := lhs
+		quotient.Divide(&rhs)
+
+		inv := rhs.GetInverse()
+		expected := lhs
+		expected.Mul(&inv)
+		if expected.IsOverflow() {
+			expected.FullReduce()
+		}
+		if quotient != expected {
+			t.Fatalf("Expected Divide and GetInverse to agree, found: %v != %v", quotient, expected)
+		}
+	}
+}
+
+func TestUint3072_MulMatchesGeneric(t *testing.T) {
+	t.Parallel()
+	check := func(lhs, rhs *uint3072) {
+		expected := *lhs
+		expected.mulGeneric(rhs)
+		res := *lhs
+		res.Mul(rhs)
+		if res != expected {
+			t.Fatalf("Expected Mul(%v, %v) to match the generic implementation, found: %v != %v", lhs, rhs, res, expected)
+		}
+		squared := *lhs
+		squared.Mul(&squared)
+		expected = *lhs
+		expected.mulGeneric(lhs)
+		if squared != expected {
+			t.Fatalf("Expected Mul(%v, %v) to match the generic implementation, found: %v != %v", lhs, lhs, squared, expected)
+		}
+	}
+
+	var max, zero uint3072
+	for i := range max {
+		max[i] = maxUint
+	}
+	prime := max
+	prime[0] -= primeDiff - 1
+	regularOne := one()
+	edges := []uint3072{zero, regularOne, max, prime}
+	for i := range edges {
+		for j := range edges {
+			check(&edges[i], &edges[j])
+		}
+	}
+
+	r := rand.New(rand.NewSource(0))
+	for i := 0; i < loopsN; i++ {
+		var lhs, rhs uint3072
+		for j := range lhs {
+			lhs[j] = uint(r.Uint64())
+			rhs[j] = uint(r.Uint64())
+		}
+		check(&lhs, &rhs)
+	}
+
+	corpus, err := os.ReadDir("corpus")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range corpus {
+		data, err := os.ReadFile(filepath.Join("corpus", entry.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		lhs := one()
+		for start := 0; start+elementByteSize <= len(data); start += elementByteSize {
+			var rhs uint3072
+			for i := range rhs {
+				for j := wordSizeInBytes - 1; j >= 0; j-- {
+					rhs[i] = rhs[i]<<8 | uint(data[start+i*wordSizeInBytes+j])
+				}
+			}
+			check(&lhs, &rhs)
+			lhs.Mul(&rhs)
+		}
+	}
+}
+
+func BenchmarkUint3072_Mul(b *testing.B) {
+	r := rand.New(rand.NewSource(0))
+	var lhs, rhs uint3072
+	for i := range lhs {
+		lhs[i] = uint(r.Uint64())
+		rhs[i] = uint(r.Uint64())
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		lhs.Mul(&rhs)
+	}
+}
+
+func BenchmarkUint3072_mulGeneric(b *testing.B) {
+	r := rand.New(rand.NewSource(0))
+	var lhs, rhs uint3072
+	for i := range lhs {
+		lhs[i] = uint(r.Uint64())
+		rhs[i] = uint(r.Uint64())
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		lhs.mulGeneric(&rhs)
+	}
+}
+
+// BenchmarkUint3072_FullReduce measures the worst case input, 2^3072-1, whose carry propagates through all the limbs.
+func BenchmarkUint3072_FullReduce(b *testing.B) {
+	var max uint3072
+	for i := range max {
+		max[i] = maxUint
+	}
+	var lhs uint3072
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		lhs = max
+		lhs.FullReduce()
+	}
+}
+
+// BenchmarkUint3072_Square and BenchmarkUint3072_MulSelf compare squaring with Square and with Mul,
+// which is what GetInverse uses.
+func BenchmarkUint3072_Square(b *testing.B) {
+	r := rand.New(rand.NewSource(0))
+	var element uint3072
+	for i := range element {
+		element[i] = uint(r.Uint64())
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		element.Square()
+	}
+}
+
+func BenchmarkUint3072_MulSelf(b *testing.B) {
+	r := rand.New(rand.NewSource(0))
+	var element uint3072
+	for i := range element {
+		element[i] = uint(r.Uint64())
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		element.Mul(&element)
+	}
+}
+
+func BenchmarkUint3072_GetInverse(b *testing.B) {
+	r := rand.New(rand.NewSource(0))
+	var element uint3072
+	for i := range element {
+		element[i] = uint(r.Uint64())
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		element.GetInverse()
+	}
+}
+
+// BenchmarkUint3072_Divide and BenchmarkUint3072_DivideGetInverse compare the big.Int based
+// modular inversion used by Divide against the pure Go exponentiation in GetInverse.
+func BenchmarkUint3072_Divide(b *testing.B) {
+	r := rand.New(rand.NewSource(0))
+	var rhs uint3072
+	for i := range rhs {
+		rhs[i] = uint(r.Uint64())
+	}
+	lhs := one()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		lhs.Divide(&rhs)
+	}
+}
+
+func BenchmarkUint3072_DivideGetInverse(b *testing.B) {
+	r := rand.New(rand.NewSource(0))
+	var rhs uint3072
+	for i := range rhs {
+		rhs[i] = uint(r.Uint64())
+	}
+	lhs := one()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		inv := rhs.GetInverse()
+		lhs.Mul(&inv)
+	}
+}
+
+func TestUint3072_batchInverse(t *testing.T) {
+	t.Parallel()
+	r := rand.New(rand.NewSource(0))
+	elements := make([]uint3072, 10)
+	for i := range elements {
+		for j := range elements[i] {
+			elements[i][j] = uint(r.Uint64())
+		}
+	}
+	// Zero, and the prime itself which is equal to zero.
+	elements[3] = uint3072{}
+	for i := range elements[7] {
+		elements[7][i] = maxUint
+	}
+	elements[7][0] -= primeDiff - 1
+
+	expected := make([]uint3072, len(elements))
+	for i := range elements {
+		expected[i] = elements[i].modInverse()
+	}
+	batchInverse(elements)
+	for i := range elements {
+		if elements[i] != expected[i] {
+			t.Fatalf("Element #%d: Expected %v == %v", i, elements[i], expected[i])
+		}
+	}
+	if !elements[3].IsZero() || !elements[7].IsZero() {
+		t.Fatalf("Expected zero elements to stay zero, found: %v, %v", elements[3], elements[7])
+	}
+
+	batchInverse(nil)
+}